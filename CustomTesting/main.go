@@ -7,11 +7,19 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
 	network "github.com/Ammar123890/Mid-Level-Blockchain/Network"
@@ -25,15 +33,147 @@ import (
 
 func main() {
 	port := flag.String("port", "8001", "Port on which the node will listen")
+	autoProduce := flag.Bool("autoproduce", false, "Start the automatic block producer on startup")
+	maxWait := flag.Duration("maxwait", network.DefaultMaxBlockWait, "Maximum time a pending transaction waits before a block is produced")
+	mempoolFile := flag.String("mempool", "", "File in which the pending transactions are persisted (default mempool_<port>.json)")
+	mempoolInterval := flag.Duration("mempool-interval", 30*time.Second, "How often the pending transactions are persisted while running")
+	storeType := flag.String("store", "file", "Block storage backend: file or memory")
+	dataDir := flag.String("datadir", "", "Directory of the on-disk block store (default data_<port>)")
+	reindex := flag.Bool("reindex", false, "Rebuild the block index and the pending transactions from the raw block data, verify the chain and exit")
+	exportFile := flag.String("export", "", "Export the chain to the snapshot file and exit")
+	importFile := flag.String("import", "", "Import the snapshot file into the chain and exit")
+	format := flag.String("format", MidLevelBlockchain.SnapshotFormatJSONLines, "Snapshot format of -export: jsonl or binary")
+	fromHeight := flag.Int("from", 0, "First block height exported by -export")
+	toHeight := flag.Int("to", -1, "Last block height exported by -export (default the tip)")
+	seeds := flag.String("seeds", "localhost:8001,localhost:8002", "Comma-separated bootstrap addresses of the peer discovery")
+	configFile := flag.String("config", "", "JSON config file with the bootstrap Seeds and MaxOutbound connections")
+	maxOutbound := flag.Int("maxpeers", network.DefaultMaxOutbound, "Outbound connections opened by the peer discovery")
+	maxInbound := flag.Int("maxinbound", network.DefaultMaxInbound, "Inbound connections accepted at the same time")
+	maxInboundPerIP := flag.Int("maxinbound-perip", network.DefaultMaxInboundPerIP, "Inbound connections accepted at the same time from a single IP address, loopback excepted")
+	chainID := flag.String("chainid", network.DefaultChainID, "Chain of the node, peers on another chain are refused")
+	pruneDepth := flag.Int("prune", 0, "Delete the transactions of blocks deeper than this, only their headers are kept (0 keeps every block)")
+	simulate := flag.Int("simulate", 0, "Run an in-process DHT simulation with this many nodes and exit")
+	simulatePort := flag.Int("simport", 20000, "First port used by the nodes of -simulate")
+	trustedKeys := flag.String("trusted-keys", "", "Comma-separated hex public keys of the peers accepted on a private network (default any peer)")
+	banDuration := flag.Duration("banduration", network.DefaultBanDuration, "How long a misbehaving peer is banned")
+	simulateMode := flag.String("simmode", "dht", "What -simulate measures: dht (lookups) or gossip (propagation over a line of nodes)")
+	simulateNet := flag.String("simnet", "tcp", "Network of the -simulate nodes: tcp or memory")
+	simulateSeed := flag.Int64("simseed", 1, "Seed of the faults of the memory network")
+	simulateLatency := flag.Duration("simlatency", 0, "One-way latency of the memory network")
+	simulateJitter := flag.Duration("simjitter", 0, "Random extra latency of the memory network, up to this")
+	simulateLoss := flag.Float64("simloss", 0, "Probability that the memory network loses a write, which arrives 200ms later and holds back the next ones")
+	simulateReset := flag.Float64("simreset", 0, "Probability that a write on the memory network resets its connection")
+	codecList := flag.String("codecs", strings.Join(network.DefaultCodecs, ","), "Comma-separated wire codecs offered to the peers, most preferred first: binary, json (json alone eases debugging)")
 	flag.Parse()
+	codecs, err := network.ParseCodecs(*codecList)
+	if err != nil {
+		fmt.Println("Error parsing the codecs:", err)
+		os.Exit(1)
+	}
+	if *simulate > 0 {
+		var memnet *network.MemoryNetwork
+		switch *simulateNet {
+		case "memory":
+			memnet = network.NewMemoryNetwork(*simulateSeed)
+			memnet.Latency = *simulateLatency
+			memnet.Jitter = *simulateJitter
+			memnet.LossRate = *simulateLoss
+			memnet.ResetRate = *simulateReset
+		case "tcp":
+		default:
+			fmt.Printf("Unknown simulation network %q\n", *simulateNet)
+			os.Exit(1)
+		}
+		os.Exit(runSimulation(*simulateMode, *simulate, *simulatePort, memnet, codecs))
+	}
+	if *dataDir == "" {
+		*dataDir = "data_" + *port
+	}
+	if *mempoolFile == "" {
+		*mempoolFile = "mempool_" + *port + ".json"
+	}
+	if *reindex {
+		os.Exit(reindexBlockchain(*dataDir, *mempoolFile))
+	}
 
 	nodeAddress := "localhost:" + *port
 
-	node := network.Node{
-		Blockchain: &MidLevelBlockchain.Blockchain{},
-		Address:    nodeAddress,
+	blockchain, err := openBlockchain(*storeType, *dataDir)
+	if err != nil {
+		fmt.Println("Error opening the blockchain:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Loaded %d block(s) from the %s block store.\n", blockchain.Height(), *storeType)
+	blockchain.PruneDepth = *pruneDepth
+	if err := blockchain.Prune(); err != nil {
+		fmt.Println("Error pruning the blockchain:", err)
+	}
+	if *exportFile != "" || *importFile != "" {
+		code := 0
+		if *exportFile != "" {
+			code = exportBlockchain(blockchain, *exportFile, *format, *fromHeight, *toHeight)
+		} else {
+			code = importBlockchain(blockchain, *importFile)
+		}
+		if err := blockchain.Close(); err != nil {
+			fmt.Println("Error closing the block store:", err)
+			code = 1
+		}
+		os.Exit(code)
+	}
+
+	node := network.NewNode(blockchain, MidLevelBlockchain.NewMempool(blockchain), nodeAddress)
+	node.ChainID = *chainID
+	identity, err := network.LoadOrCreateIdentity(filepath.Join(*dataDir, "node.key"))
+	if err != nil {
+		fmt.Println("Error loading the node identity:", err)
+		os.Exit(1)
+	}
+	node.SetIdentity(identity)
+	node.MaxOutbound = *maxOutbound
+	node.MaxInbound = *maxInbound
+	node.MaxInboundPerIP = *maxInboundPerIP
+	node.AddressBook = network.NewAddressBook(filepath.Join(*dataDir, "peers.json"))
+	if err := node.AddressBook.Load(); err != nil {
+		fmt.Println("Error loading the address book:", err)
+	}
+	node.BanDuration = *banDuration
+	node.Codecs = codecs
+	node.BanList = network.NewBanList(filepath.Join(*dataDir, "banlist.json"))
+	if err := node.BanList.Load(); err != nil {
+		fmt.Println("Error loading the ban list:", err)
+	}
+	bootstrap := splitList(*seeds)
+	trusted := splitList(*trustedKeys)
+	if *configFile != "" {
+		config, err := loadConfig(*configFile)
+		if err != nil {
+			fmt.Println("Error loading the config file:", err)
+			os.Exit(1)
+		}
+		bootstrap = append(bootstrap, config.Seeds...)
+		trusted = append(trusted, config.TrustedKeys...)
+		if config.MaxOutbound > 0 {
+			node.MaxOutbound = config.MaxOutbound
+		}
+	}
+	node.TrustedKeys, err = network.ParseTrustedKeys(trusted)
+	if err != nil {
+		fmt.Println("Error parsing the trusted keys:", err)
+		os.Exit(1)
+	}
+	loadMempool(node.Mempool, *mempoolFile)
+	go persistMempool(node.Mempool, *mempoolFile, *mempoolInterval)
+	go shutdownOnInterrupt(node, *mempoolFile)
+
+	if err := node.Start(context.Background()); err != nil {
+		fmt.Println("Error starting the node:", err)
+		os.Exit(1)
+	}
+	node.StartDiscovery(bootstrap)
+	if *autoProduce {
+		node.StartBlockProducer(*maxWait)
 	}
-	go node.StartServer()
 	//	blockchain := MidLevelBlockchain.Blockchain{}
 	reader := bufio.NewReader(os.Stdin)
 	testTransaction := "Sample Transaction Data"
@@ -46,7 +186,16 @@ func main() {
 		fmt.Println("3. Change a Block's Transaction")
 		fmt.Println("4. Verify Blockchain")
 		fmt.Println("5. Set number of transactions per block")
-		fmt.Println("6. Exit")
+		fmt.Println("6. Add a Transaction to the Pool")
+		fmt.Println("7. Display Pending Transactions")
+		fmt.Println("8. Start Automatic Block Production")
+		fmt.Println("9. Stop Automatic Block Production")
+		fmt.Println("10. Replace or Cancel a Pending Transaction")
+		fmt.Println("11. Add a Peer")
+		fmt.Println("12. Remove a Peer")
+		fmt.Println("13. Display Peers")
+		fmt.Println("14. Manage Banned Peers")
+		fmt.Println("15. Exit")
 		fmt.Print("Enter your choice: ")
 
 		choiceStr, _ := reader.ReadString('\n')
@@ -59,7 +208,7 @@ func main() {
 
 		switch choice {
 		case 1:
-			mineAndBroadcastBlock(node.Blockchain, &node)
+			mineAndBroadcastBlock(node.Blockchain, node)
 		case 2:
 			displayBlocks(node.Blockchain)
 		case 3:
@@ -69,8 +218,26 @@ func main() {
 		case 5:
 			setNumberOfTransactionsPerBlock(node.Blockchain, reader)
 		case 6:
+			addTransaction(node, reader)
+		case 7:
+			displayPendingTransactions(node.Mempool)
+		case 8:
+			startBlockProducer(node, reader)
+		case 9:
+			stopBlockProducer(node)
+		case 10:
+			replaceTransaction(node, reader)
+		case 11:
+			addPeer(node, reader)
+		case 12:
+			removePeer(node, reader)
+		case 13:
+			displayPeers(node)
+		case 14:
+			manageBans(node, reader)
+		case 15:
 			fmt.Println("Exiting the blockchain application.")
-			os.Exit(0)
+			shutdown(node, *mempoolFile)
 		default:
 			fmt.Println("Invalid choice. Please select a valid option.")
 		}
@@ -100,15 +267,13 @@ func mineAndBroadcastBlock(bc *MidLevelBlockchain.Blockchain, node *network.Node
 		transactions[i] = strings.TrimSpace(transaction)
 	}
 
-	previousHash := ""
-	if len(bc.Blocks) > 0 {
-		previousHash = bc.Blocks[len(bc.Blocks)-1].CurrentHash
-	}
-
-	newBlock := bc.MineBlock(transactions, previousHash)
+	newBlock := bc.MineBlock(transactions, bc.LatestHash())
 	if newBlock != nil {
+		node.Mempool.RemoveBlockTransactions(newBlock)
 		fmt.Println("New block mined successfully. Broadcasting...")
-		node.BroadcastNewBlock(newBlock)
+		ctx, cancel := context.WithTimeout(context.Background(), network.DefaultBroadcastWait)
+		defer cancel()
+		fmt.Println(node.BroadcastNewBlock(newBlock).Wait(ctx))
 	} else {
 		fmt.Println("Failed to mine a new block.")
 	}
@@ -133,7 +298,7 @@ func displayBlocks(bc *MidLevelBlockchain.Blockchain) {
  **/
 
 func changeBlock(bc *MidLevelBlockchain.Blockchain, reader *bufio.Reader) {
-	if len(bc.Blocks) == 0 {
+	if bc.Height() == 0 {
 		fmt.Println("No blocks to change.")
 		return
 	}
@@ -142,7 +307,7 @@ func changeBlock(bc *MidLevelBlockchain.Blockchain, reader *bufio.Reader) {
 	indexStr, _ := reader.ReadString('\n')
 	indexStr = strings.TrimSpace(indexStr)
 	index, err := strconv.Atoi(indexStr)
-	if err != nil || index < 0 || index >= len(bc.Blocks) {
+	if err != nil || index < 0 || index >= bc.Height() {
 		fmt.Println("Invalid block index.")
 		return
 	}
@@ -194,3 +359,539 @@ func setNumberOfTransactionsPerBlock(bc *MidLevelBlockchain.Blockchain, reader *
 	bc.SetNumberOfTransactionsPerBlock(num)
 	fmt.Printf("Number of transactions per block set to %d.\n", num)
 }
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add a transaction to the mempool and broadcast it to the other nodes
+ * @param: node *network.Node, and  reader *bufio.Reader for reading the input from the user.
+ **/
+
+func addTransaction(node *network.Node, reader *bufio.Reader) {
+	fmt.Print("Enter the transaction: ")
+	data, _ := reader.ReadString('\n')
+	data = strings.TrimSpace(data)
+	tx := MidLevelBlockchain.NewTransaction(data)
+
+	fmt.Print("Sign the transaction with the node key so it can be replaced later? (y/n): ")
+	sign, _ := reader.ReadString('\n')
+	if strings.EqualFold(strings.TrimSpace(sign), "y") {
+		var ok bool
+		if tx.Nonce, ok = readInt(reader, "Enter the sender nonce: "); !ok {
+			return
+		}
+		if tx.Fee, ok = readInt(reader, "Enter the fee: "); !ok {
+			return
+		}
+
+		fmt.Print("Enter the spent inputs (comma-separated, optional): ")
+		inputsStr, _ := reader.ReadString('\n')
+		for _, input := range strings.Split(inputsStr, ",") {
+			if input = strings.TrimSpace(input); input != "" {
+				tx.Inputs = append(tx.Inputs, input)
+			}
+		}
+		tx.Sign(node.Identity) // The node key is the sender
+	}
+
+	submitTransaction(node, tx)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to replace a pending transaction with a higher fee or to cancel it
+ * @param: node *network.Node, and  reader *bufio.Reader for reading the input from the user.
+ **/
+
+func replaceTransaction(node *network.Node, reader *bufio.Reader) {
+	fmt.Print("Enter the hash (or hash prefix) of the pending transaction: ")
+	hash, _ := reader.ReadString('\n')
+	original := node.Mempool.FindTransaction(strings.TrimSpace(hash))
+	if original == nil {
+		fmt.Println("No single pending transaction matches the hash.")
+		return
+	}
+	if original.Sender != node.PublicKey() && (original.Sender != "" || len(original.Inputs) == 0) {
+		fmt.Println("Only transactions signed with the key of this node, or spending inputs without a sender, can be replaced from here.")
+		return
+	}
+
+	fee, ok := readInt(reader, fmt.Sprintf("Enter the new fee (current fee %d): ", original.Fee))
+	if !ok {
+		return
+	}
+
+	fmt.Print("Enter the new transaction data (leave empty to cancel the transaction): ")
+	data, _ := reader.ReadString('\n')
+	data = strings.TrimSpace(data)
+
+	replacement := MidLevelBlockchain.NewCancellation(original, fee)
+	if data != "" {
+		replacement.Data = data
+	}
+	replacement.Sign(node.Identity)
+	submitTransaction(node, replacement)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add the transaction to the mempool and broadcast it, replacements are relayed the same way
+ * @param: node *network.Node, instance of transaction
+ **/
+
+func submitTransaction(node *network.Node, tx *MidLevelBlockchain.Transaction) {
+	replaced, err := node.Mempool.AddTransaction(tx)
+	if err != nil {
+		fmt.Println("Transaction rejected:", err)
+		return
+	}
+
+	node.BroadcastNewTransaction(tx.Encode())
+	if len(replaced) > 0 {
+		fmt.Printf("Transaction replaced %d pending transaction(s).\n", len(replaced))
+	}
+	fmt.Printf("Transaction added to the pool. %d transaction(s) pending.\n", node.Mempool.Count())
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read a non-negative integer from the user
+ * @param: reader *bufio.Reader for reading the input from the user, prompt string
+ * @return: int and false if the input is invalid
+ **/
+
+func readInt(reader *bufio.Reader, prompt string) (int, bool) {
+	fmt.Print(prompt)
+	numStr, _ := reader.ReadString('\n')
+	num, err := strconv.Atoi(strings.TrimSpace(numStr))
+	if err != nil || num < 0 {
+		fmt.Println("Invalid number. Please enter a non-negative integer.")
+		return 0, false
+	}
+	return num, true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to display the pending transactions of the mempool
+ * @param: instance of mempool
+ **/
+
+func displayPendingTransactions(mp *MidLevelBlockchain.Mempool) {
+	fmt.Println("\nPending transactions:")
+	mp.DisplayTransactions()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to start the automatic block production
+ * @param: node *network.Node, and  reader *bufio.Reader for reading the input from the user.
+ **/
+
+func startBlockProducer(node *network.Node, reader *bufio.Reader) {
+	fmt.Printf("Enter the maximum wait time (e.g. 30s, default %s): ", network.DefaultMaxBlockWait)
+	waitStr, _ := reader.ReadString('\n')
+	waitStr = strings.TrimSpace(waitStr)
+
+	maxWait := network.DefaultMaxBlockWait
+	if len(waitStr) > 0 {
+		parsed, err := time.ParseDuration(waitStr)
+		if err != nil || parsed <= 0 {
+			fmt.Println("Invalid duration. Please enter a positive duration such as 30s.")
+			return
+		}
+		maxWait = parsed
+	}
+
+	if node.StartBlockProducer(maxWait) {
+		fmt.Printf("Automatic block production started (maximum wait %s).\n", maxWait)
+	} else {
+		fmt.Println("Automatic block production is already running.")
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to stop the automatic block production
+ * @param: node *network.Node
+ **/
+
+func stopBlockProducer(node *network.Node) {
+	if node.StopBlockProducer() {
+		fmt.Println("Automatic block production stopped.")
+	} else {
+		fmt.Println("Automatic block production is not running.")
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to reload the pending transactions which were persisted by the previous run
+ * @param: instance of mempool, path string of the file
+ **/
+
+func loadMempool(mp *MidLevelBlockchain.Mempool, path string) {
+	loaded, dropped, err := mp.LoadFromFile(path)
+	if err != nil {
+		fmt.Println("Error loading the pending transactions:", err)
+		return
+	}
+	if loaded > 0 || dropped > 0 {
+		fmt.Printf("Reloaded %d pending transaction(s), dropped %d invalid one(s).\n", loaded, dropped)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to persist the pending transactions periodically while the node is running
+ * @param: instance of mempool, path string of the file, interval time.Duration
+ **/
+
+func persistMempool(mp *MidLevelBlockchain.Mempool, path string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := mp.SaveToFile(path); err != nil {
+			fmt.Println("Error saving the pending transactions:", err)
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to shut down cleanly when the process is interrupted (Ctrl+C)
+ * @param: instance of node, path string of the mempool file
+ **/
+
+func shutdownOnInterrupt(node *network.Node, path string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
+
+	fmt.Println("\nInterrupted, exiting the blockchain application.")
+	shutdown(node, path)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to stop the node, persist the pending transactions and the address book, close the
+ * @description: block store and exit the application
+ * @param: instance of node, path string of the mempool file
+ **/
+
+func shutdown(node *network.Node, path string) {
+	if err := node.Stop(); err != nil {
+		fmt.Println("Error stopping the node:", err)
+	}
+	if err := node.Mempool.SaveToFile(path); err != nil {
+		fmt.Println("Error saving the pending transactions:", err)
+	}
+	if err := node.AddressBook.Save(); err != nil {
+		fmt.Println("Error saving the address book:", err)
+	}
+	if err := node.Blockchain.Close(); err != nil {
+		fmt.Println("Error closing the block store:", err)
+	}
+	os.Exit(0)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to open the blockchain on top of the selected block store
+ * @param: storeType string (file or memory), dataDir string of the on-disk store
+ * @return: instance of blockchain and error if any
+ **/
+
+func openBlockchain(storeType string, dataDir string) (*MidLevelBlockchain.Blockchain, error) {
+	switch storeType {
+	case "memory":
+		return MidLevelBlockchain.NewBlockchain(MidLevelBlockchain.NewMemoryBlockStore())
+	case "file":
+		store, err := MidLevelBlockchain.OpenFileBlockStore(dataDir)
+		if err != nil {
+			return nil, err
+		}
+		blockchain, err := MidLevelBlockchain.NewBlockchain(store)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		return blockchain, nil
+	default:
+		return nil, fmt.Errorf("unknown block store %q", storeType)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to rebuild everything from the raw block data: the block index, the chain
+ * @description: and the pending transactions which are revalidated against it. The chain is verified at the end.
+ * @param: dataDir string of the on-disk store, mempoolFile string
+ * @return: exit code of the process
+ **/
+
+func reindexBlockchain(dataDir string, mempoolFile string) int {
+	store, err := MidLevelBlockchain.OpenFileBlockStore(dataDir)
+	if err != nil {
+		fmt.Println("Error opening the block store:", err)
+		return 1
+	}
+	defer store.Close()
+
+	report, err := store.Reindex()
+	if err != nil {
+		fmt.Println("Error rebuilding the block index:", err)
+		return 1
+	}
+	fmt.Printf("Reindexed %d block(s), truncated %d byte(s) of invalid data.\n", report.ValidBlocks, report.TruncatedBytes)
+
+	blockchain, err := MidLevelBlockchain.NewBlockchain(store)
+	if err != nil {
+		fmt.Println("Error loading the blockchain:", err)
+		return 1
+	}
+
+	mempool := MidLevelBlockchain.NewMempool(blockchain)
+	loadMempool(mempool, mempoolFile)
+	if err := mempool.SaveToFile(mempoolFile); err != nil {
+		fmt.Println("Error saving the pending transactions:", err)
+		return 1
+	}
+
+	if !blockchain.VerifyChain() {
+		fmt.Println("Blockchain is invalid.")
+		return 1
+	}
+	fmt.Println("Blockchain is valid.")
+	return 0
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to export the chain, or a height range of it, to a snapshot file
+ * @param: instance of blockchain, path string of the snapshot, format string, from and to heights
+ * @return: exit code of the process
+ **/
+
+func exportBlockchain(bc *MidLevelBlockchain.Blockchain, path string, format string, from int, to int) int {
+	manifest, err := bc.Export(path, format, from, to)
+	if err != nil {
+		fmt.Println("Error exporting the blockchain:", err)
+		return 1
+	}
+	fmt.Printf("Exported blocks %d-%d to %s (%s), manifest written to %s.\n",
+		manifest.FromHeight, manifest.ToHeight, path, manifest.Format, MidLevelBlockchain.ManifestPath(path))
+	return 0
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to import a snapshot file into the chain, every block is validated before it is accepted
+ * @param: instance of blockchain, path string of the snapshot
+ * @return: exit code of the process
+ **/
+
+func importBlockchain(bc *MidLevelBlockchain.Blockchain, path string) int {
+	added, err := bc.Import(path)
+	if err != nil {
+		fmt.Println("Error importing the blockchain:", err)
+		return 1
+	}
+	fmt.Printf("Imported %d new block(s), the chain now has %d block(s).\n", added, bc.Height())
+	return 0
+}
+
+// Config is the optional JSON config file of the node.
+type Config struct {
+	Seeds       []string // Bootstrap addresses of the peer discovery
+	MaxOutbound int      // Outbound connections opened by the peer discovery
+	TrustedKeys []string // Hex public keys of the peers accepted on a private network
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to load the config file
+ * @param: path string of the config file
+ * @return: instance of config and error if any
+ **/
+
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to split a comma-separated list, empty items are skipped
+ * @param: list string
+ * @return: slice of strings
+ **/
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add a peer at runtime
+ * @param: node *network.Node, and  reader *bufio.Reader for reading the input from the user.
+ **/
+
+func addPeer(node *network.Node, reader *bufio.Reader) {
+	fmt.Print("Enter the address of the peer (host:port): ")
+	addr, _ := reader.ReadString('\n')
+	addr = strings.TrimSpace(addr)
+
+	if err := node.AddPeer(addr); err != nil {
+		fmt.Println("Could not connect to the peer:", err)
+		return
+	}
+	fmt.Printf("Connected to peer %s.\n", addr)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to remove a peer at runtime, it is not dialed again
+ * @param: node *network.Node, and  reader *bufio.Reader for reading the input from the user.
+ **/
+
+func removePeer(node *network.Node, reader *bufio.Reader) {
+	fmt.Print("Enter the address of the peer (host:port): ")
+	addr, _ := reader.ReadString('\n')
+	addr = strings.TrimSpace(addr)
+
+	node.RemovePeer(addr)
+	fmt.Printf("Peer %s removed.\n", addr)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to list the banned peers, and ban or unban a peer by address or node ID
+ * @param: node *network.Node, and  reader *bufio.Reader for reading the input from the user.
+ **/
+
+func manageBans(node *network.Node, reader *bufio.Reader) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Println("\nBanned peers:")
+	fmt.Fprintln(w, "Address or Node ID\tUntil\tReason")
+	for _, entry := range node.BanList.Entries() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", entry.Key, entry.Until.Format(time.RFC3339), entry.Reason)
+	}
+	w.Flush()
+	fmt.Println("Loopback peers are banned by node ID and announced address, not by IP: one restarted with a new key on a new port gets back in.")
+
+	fmt.Print("\nEnter b to ban a peer, u to unban a peer, or nothing to go back: ")
+	action, _ := reader.ReadString('\n')
+	action = strings.TrimSpace(action)
+	if action != "b" && action != "u" {
+		return
+	}
+	fmt.Print("Enter the address (host:port) or node ID of the peer: ")
+	key, _ := reader.ReadString('\n')
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return
+	}
+
+	if action == "b" {
+		if err := node.BanPeer(key, "banned manually"); err != nil {
+			fmt.Println("Error saving the ban list:", err)
+			return
+		}
+		fmt.Printf("Peer %s banned for %v.\n", key, node.BanDuration)
+		return
+	}
+	unbanned, err := node.UnbanPeer(key)
+	if err != nil {
+		fmt.Println("Error saving the ban list:", err)
+		return
+	}
+	if !unbanned {
+		fmt.Printf("Peer %s is not banned.\n", key)
+		return
+	}
+	fmt.Printf("Peer %s unbanned.\n", key)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to display the connected peers and the address book
+ * @param: node *network.Node
+ **/
+
+func displayPeers(node *network.Node) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Printf("\nNode ID: %s (%d DHT contact(s))\n", node.ID(), node.RoutingTable().Len())
+	fmt.Printf("Public key: %s\n", node.PublicKey())
+	fmt.Println("\nConnected peers:")
+	fmt.Fprintln(w, "Address\tDirection\tHeight\tScore\tRTT\tAvg RTT\tLast Seen\tUser Agent")
+	for _, peer := range node.Peers() {
+		direction := "outbound"
+		if peer.Inbound {
+			direction = "inbound"
+		}
+		metrics := peer.Metrics()
+		rtt, avgRTT := "-", "-"
+		if metrics.Pongs > 0 {
+			rtt, avgRTT = metrics.RTT.Round(time.Microsecond).String(), metrics.AvgRTT.Round(time.Microsecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s ago\t%s\n", peer.Address(), direction, peer.Version.BestHeight, node.MisbehaviorScore(peer.Address()),
+			rtt, avgRTT, time.Since(metrics.LastSeen).Round(time.Second), peer.Version.UserAgent)
+	}
+	w.Flush()
+
+	fmt.Println("\nAddress book:")
+	fmt.Fprintln(w, "Address\tSource\tLast Seen\tFailed Attempts")
+	for _, entry := range node.AddressBook.Entries() {
+		lastSeen := "never"
+		if !entry.LastSeen.IsZero() {
+			lastSeen = entry.LastSeen.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", entry.Address, entry.Source, lastSeen, entry.Attempts)
+	}
+	w.Flush()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to run the in-process simulation and print its report
+ * @param: mode string (dht or gossip), number of nodes, first port of the nodes, memory network (nil for TCP), wire codecs of the nodes
+ * @return: exit code
+ **/
+
+func runSimulation(mode string, nodes int, basePort int, memnet *network.MemoryNetwork, codecs []string) int {
+	fmt.Printf("Starting %d nodes on ports %d-%d...\n", nodes, basePort, basePort+nodes-1)
+	log.SetOutput(io.Discard) // The nodes are too chatty to follow
+	var report fmt.Stringer
+	var err error
+	switch mode {
+	case "dht":
+		report, err = network.RunDHTSimulation(nodes, basePort, nodes, memnet, codecs)
+	case "gossip":
+		report, err = network.RunGossipSimulation(nodes, basePort, memnet, codecs)
+	default:
+		err = fmt.Errorf("unknown simulation mode %q", mode)
+	}
+	if err != nil {
+		fmt.Println("Simulation failed:", err)
+		return 1
+	}
+	fmt.Println(report)
+	return 0
+}
@@ -4,7 +4,6 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"strings"
 )
 
 // Block represents a single block in the blockchain.
@@ -14,6 +13,7 @@ type Block struct {
 	PreviousHash string
 	CurrentHash  string
 	MerkleRoot   string // New field for Merkle root
+	Pruned       bool   `json:",omitempty"` // The transactions were deleted, only the header is kept
 }
 
 /**
@@ -49,13 +49,31 @@ func NewBlock(transactions []string, nonce int, previousHash string) *Block {
 /*
  * @createdby: Syed Muhammad Ammar
  * @description: This function is used to calculate the hash of the block
+ * @description: Only the header is hashed, the Merkle root commits to the transactions, so a pruned header can still be
+ * @description: hashed again and checked.
  * @param: instance of block
  * @return: hash of the block
  */
 
 func (b *Block) CalculateHash() string {
-	transactionData := strings.Join(b.Transactions, "")
-	data := fmt.Sprintf("%s%d%s%s", transactionData, b.Nonce, b.PreviousHash, b.MerkleRoot)
+	data := fmt.Sprintf("%d%s%s", b.Nonce, b.PreviousHash, b.MerkleRoot)
 	hash := sha256.Sum256([]byte(data))
 	return fmt.Sprintf("%x", hash)
 }
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the header of the block, i.e. a copy of the block without its transactions
+ * @param: instance of block
+ * @return: instance of pruned block
+ **/
+
+func (b *Block) Header() *Block {
+	return &Block{
+		Nonce:        b.Nonce,
+		PreviousHash: b.PreviousHash,
+		CurrentHash:  b.CurrentHash,
+		MerkleRoot:   b.MerkleRoot,
+		Pruned:       true,
+	}
+}
@@ -1,16 +1,167 @@
 package MidLevelBlockchain
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"text/tabwriter"
 )
 
-// Blockchain represents a blockchain.
+// Blockchain represents a blockchain. It is safe for concurrent use: a block is never changed once it is in the
+// chain, a change replaces it with a copy, so the blocks returned by Snapshot, Tip and BlockAt can be read freely.
 type Blockchain struct {
-	Blocks []*Block
+	Store      BlockStore // Optional storage backend, the blocks are only kept in memory if it is nil
+	PruneDepth int        // Transactions of blocks deeper than this are deleted, 0 keeps every block
+
+	mu     sync.RWMutex
+	blocks []*Block
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the blockchain on top of the block store and load the stored blocks
+ * @param: store BlockStore
+ * @return: instance of blockchain and error if any
+ **/
+
+func NewBlockchain(store BlockStore) (*Blockchain, error) {
+	blocks, err := store.Blocks()
+	if err != nil {
+		return nil, err
+	}
+	return &Blockchain{blocks: blocks, Store: store}, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to close the block store, the chain must not be changed afterwards
+ * @return: error if any
+ **/
+
+func (bc *Blockchain) Close() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.Store == nil {
+		return nil
+	}
+	return bc.Store.Close()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the number of blocks of the chain
+ * @return: int
+ **/
+
+func (bc *Blockchain) Height() int {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return len(bc.blocks)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the last block of the chain
+ * @return: instance of block, nil if there are no blocks
+ **/
+
+func (bc *Blockchain) Tip() *Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if len(bc.blocks) == 0 {
+		return nil
+	}
+	return bc.blocks[len(bc.blocks)-1]
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the block at the height
+ * @param: height int
+ * @return: instance of block, nil if the height is out of range
+ **/
+
+func (bc *Blockchain) BlockAt(height int) *Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if height < 0 || height >= len(bc.blocks) {
+		return nil
+	}
+	return bc.blocks[height]
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get a copy of the blocks of the chain, it is not changed by later updates
+ * @return: slice of blocks from the first block to the tip
+ **/
+
+func (bc *Blockchain) Snapshot() []*Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return append([]*Block(nil), bc.blocks...)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add the block on top of the chain, it is written to the block store first
+ * @description: The block must link to the tip when it is added, so a block mined on a tip which changed meanwhile is refused.
+ * @param: instance of block
+ * @return: error wrapping ErrUnknownParent, or error if the block could not be persisted
+ **/
+
+func (bc *Blockchain) AddBlock(block *Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.addBlockLocked(block)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to validate the block with the rules of ValidateNextBlock and add it, as one update
+ * @param: instance of block
+ * @return: ErrKnownBlock if the block is already in the chain, or the error of ValidateNextBlock or AddBlock
+ **/
+
+func (bc *Blockchain) AcceptBlock(block *Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.heightOfLocked(block.CurrentHash) >= 0 {
+		return ErrKnownBlock
+	}
+	if err := bc.validateNextBlockLocked(block); err != nil {
+		return err
+	}
+	return bc.addBlockLocked(block)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add the block, it must be called with the write lock held
+ * @param: instance of block
+ * @return: error if any
+ **/
+
+func (bc *Blockchain) addBlockLocked(block *Block) error {
+	if block.PreviousHash != bc.latestHashLocked() {
+		return fmt.Errorf("%w: parent %s", ErrUnknownParent, limitHashDisplay(block.PreviousHash, 16))
+	}
+	if bc.Store != nil {
+		if err := bc.Store.Append(block); err != nil {
+			return err
+		}
+	}
+	bc.blocks = append(bc.blocks, block)
+
+	if bc.PruneDepth > 0 && len(bc.blocks)-bc.prunedHeightLocked() >= bc.PruneDepth+pruneBatchSize {
+		if err := bc.pruneLocked(); err != nil {
+			fmt.Println("Error pruning the blockchain:", err)
+		}
+	}
+	return nil
 }
 
 /**
@@ -24,38 +175,118 @@ type Blockchain struct {
 
 const initialDifficulty = 2            // Initial difficulty level
 const difficultyAdjustmentInterval = 5 // Interval at which the difficulty will increase
+const miningCancelInterval = 1024      // Nonces tried between two checks for the cancellation of the mining
 
 func (bc *Blockchain) MineBlock(transactions []string, previousHash string) *Block {
-	var nonce int = 0
+	return bc.MineBlockContext(context.Background(), transactions, previousHash)
+}
 
-	// Determine the current difficulty
-	currentDifficulty := initialDifficulty + (len(bc.Blocks) / difficultyAdjustmentInterval)
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to mine a new block as MineBlock does, the mining stops when the context is done
+ * @param: context of the mining, transactions in string , previousHash string
+ * @return: instance of block, nil if the mining was cancelled
+ **/
 
+func (bc *Blockchain) MineBlockContext(ctx context.Context, transactions []string, previousHash string) *Block {
 	// Check if enough transactions are available to mine a block
 	if len(transactions) < minTransactionsPerBlock {
 		fmt.Println("Not enough transactions to mine a new block.")
 		return nil
 	}
 
+	return bc.ForceMineBlockContext(ctx, transactions, previousHash)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to mine a new block without checking the minimum number of transactions per block
+ * @description: It is used by the block producer once the maximum wait time for the pending transactions has passed
+ * @param: transactions in string , previousHash string
+ * @return: instance of block
+ **/
+
+func (bc *Blockchain) ForceMineBlock(transactions []string, previousHash string) *Block {
+	return bc.ForceMineBlockContext(context.Background(), transactions, previousHash)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to mine a new block as ForceMineBlock does, the mining stops when the context is
+ * @description: done, so a node which is stopped does not wait for the nonce to be found
+ * @param: context of the mining, transactions in string , previousHash string
+ * @return: instance of block, nil if the mining was cancelled
+ **/
+
+func (bc *Blockchain) ForceMineBlockContext(ctx context.Context, transactions []string, previousHash string) *Block {
+	if len(transactions) == 0 {
+		fmt.Println("No transactions to mine a new block.")
+		return nil
+	}
+
+	// Determine the difficulty of the height following the previous block
+	block := mineBlock(ctx, transactions, previousHash, difficultyAt(bc.HeightOf(previousHash)+1))
+	if block == nil {
+		fmt.Println("Mining was cancelled.")
+		return nil
+	}
+
+	// Add the new block to the blockchain
+	if err := bc.AddBlock(block); err != nil {
+		fmt.Println("Error storing the block:", err)
+		return nil
+	}
+	fmt.Println("Block added successfully.")
+
+	return block
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to mine a block on top of the current tip without adding it to the chain
+ * @description: It is used to build the blocks which another node would send, the block is refused if the tip changed meanwhile.
+ * @param: transactions in string
+ * @return: instance of block, nil if there are no transactions
+ **/
+
+func (bc *Blockchain) MineOnTip(transactions []string) *Block {
+	if len(transactions) == 0 {
+		return nil
+	}
+	bc.mu.RLock()
+	previousHash, height := bc.latestHashLocked(), len(bc.blocks)
+	bc.mu.RUnlock()
+	return mineBlock(context.Background(), transactions, previousHash, difficultyAt(height))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to find the nonce whose block hash meets the difficulty
+ * @description: The context is checked every miningCancelInterval nonces.
+ * @param: context of the mining, transactions in string, previousHash string, difficulty int
+ * @return: instance of block, nil if the context is done first
+ **/
+
+func mineBlock(ctx context.Context, transactions []string, previousHash string, difficulty int) *Block {
+	var nonce int = 0
+
 	fmt.Println("Mining a new block")
 	block := NewBlock(transactions, nonce, previousHash)
 
 	for {
 		hash := block.CalculateHash()
-		if isValidHash(hash, currentDifficulty) {
+		if isValidHash(hash, difficulty) {
 			fmt.Printf("Block mined with hash: %s\n", hash)
 			block.CurrentHash = hash
 			break
 		} else {
+			if nonce%miningCancelInterval == 0 && ctx.Err() != nil {
+				return nil
+			}
 			nonce++
 			block.Nonce = nonce // Update the nonce for the next iteration
 		}
 	}
-
-	// Add the new block to the blockchain
-	bc.Blocks = append(bc.Blocks, block)
-	fmt.Println("Block added successfully.")
-
 	return block
 }
 
@@ -81,12 +312,17 @@ func (bc *Blockchain) DisplayBlocks() {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
 	fmt.Fprintln(w, "Block\tTransaction\tNonce\tPrevious Hash\tCurrent Hash")
-	for i, block := range bc.Blocks {
+	for i, block := range bc.Snapshot() {
 		// Limit hash display to 16 characters and append "..." if it exceeds that length
 		prevHash := limitHashDisplay(block.PreviousHash, 16)
 		currHash := limitHashDisplay(block.CurrentHash, 16)
 
-		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\n", i, strings.Join(block.Transactions, ", "), block.Nonce, prevHash, currHash)
+		transactions := strings.Join(block.Transactions, ", ")
+		if block.Pruned {
+			transactions = "(pruned)"
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\n", i, transactions, block.Nonce, prevHash, currHash)
 
 	}
 
@@ -110,27 +346,43 @@ func limitHashDisplay(hash string, maxLength int) string {
 
 /**
  * @createdby: Syed Muhammad Ammar
- * @description: This function is used to change the block, It doest not change the hashes of the blocks.
+ * @description: This function is used to change the block, the hashes of the following blocks are recalculated.
+ * @description: The changed blocks replace the stored ones from the changed height on, so the store keeps matching the
+ * @description: chain and the next block appended links to the changed tip. Nothing is changed if the store fails.
+ * @description: The changed blocks are replaced with copies, so snapshots taken before keep the original blocks.
  * @param: instance of blockchain, and  reader *bufio.Reader for reading the input from the user.
  **/
 
 func (bc *Blockchain) ChangeBlock(blockIndex int, newTransaction string) {
-	if blockIndex < 0 || blockIndex >= len(bc.Blocks) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if blockIndex < 0 || blockIndex >= len(bc.blocks) {
 		fmt.Println("Invalid block index")
 		return
 	}
 
-	for i := blockIndex; i < len(bc.Blocks); i++ {
-		if i > 0 {
-			bc.Blocks[i].PreviousHash = bc.Blocks[i-1].CurrentHash
+	var changedBlocks []*Block
+	for i := blockIndex; i < len(bc.blocks); i++ {
+		changed := *bc.blocks[i]
+		if i > blockIndex {
+			changed.PreviousHash = changedBlocks[len(changedBlocks)-1].CurrentHash
 		}
 
 		if i == blockIndex {
-			bc.Blocks[i].Transactions = append(bc.Blocks[i].Transactions, newTransaction)
+			changed.Transactions = append(append([]string(nil), changed.Transactions...), newTransaction)
 		}
 
-		bc.Blocks[i].CurrentHash = bc.Blocks[i].CalculateHash()
+		changed.CurrentHash = changed.CalculateHash()
+		changedBlocks = append(changedBlocks, &changed)
+	}
+
+	if bc.Store != nil {
+		if err := bc.Store.ReplaceFrom(blockIndex, changedBlocks); err != nil {
+			fmt.Println("Error saving the changed blocks:", err)
+			return
+		}
 	}
+	copy(bc.blocks[blockIndex:], changedBlocks)
 }
 
 /**
@@ -141,36 +393,58 @@ func (bc *Blockchain) ChangeBlock(blockIndex int, newTransaction string) {
  **/
 
 func (bc *Blockchain) VerifyChain() bool {
-	for i := 0; i < len(bc.Blocks); i++ {
-		currentBlock := bc.Blocks[i]
-
-		// Check block's content hash matches its current hash
-		if currentBlock.CurrentHash != currentBlock.CalculateHash() {
-			return false
+	blocks := bc.Snapshot()
+	for i := 0; i < len(blocks); i++ {
+		var previousBlock *Block
+		if i > 0 {
+			previousBlock = blocks[i-1]
 		}
 
-		// For all blocks except the first, check if previous hash matches
-		if i > 0 {
-			previousBlock := bc.Blocks[i-1]
-			if currentBlock.PreviousHash != previousBlock.CurrentHash {
+		// The pruned range has no transactions left, it is validated using the headers only
+		if blocks[i].Pruned {
+			if !VerifyHeader(blocks[i], previousBlock, i) {
 				return false
 			}
+			continue
 		}
-
-		// Check Merkle root integrity
-		var txData [][]byte
-		for _, tx := range currentBlock.Transactions {
-			txData = append(txData, []byte(tx))
-		}
-
-		tree := NewMerkleTree(txData)
-		if currentBlock.MerkleRoot != hex.EncodeToString(tree.RootNode.Data) {
+		if !VerifyBlock(blocks[i], previousBlock) {
 			return false
 		}
 	}
 	return true
 }
 
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to verify a single block with the rules of VerifyChain
+ * @param: instance of block, previous block (nil for the first block of the chain)
+ * @return: bool
+ **/
+
+func VerifyBlock(currentBlock *Block, previousBlock *Block) bool {
+	// Check block's content hash matches its current hash
+	if currentBlock.CurrentHash != currentBlock.CalculateHash() {
+		return false
+	}
+
+	// For all blocks except the first, check if previous hash matches
+	if previousBlock != nil && currentBlock.PreviousHash != previousBlock.CurrentHash {
+		return false
+	}
+
+	// Check Merkle root integrity, a block without transactions has no Merkle tree
+	if len(currentBlock.Transactions) == 0 {
+		return false
+	}
+	var txData [][]byte
+	for _, tx := range currentBlock.Transactions {
+		txData = append(txData, []byte(tx))
+	}
+
+	tree := NewMerkleTree(txData)
+	return currentBlock.MerkleRoot == hex.EncodeToString(tree.RootNode.Data)
+}
+
 /**
  * @createdby: Syed Muhammad Ammar
  * @description: This function is used to set the number of transactions per block
@@ -182,3 +456,88 @@ var minTransactionsPerBlock int = 2 // default
 func (bc *Blockchain) SetNumberOfTransactionsPerBlock(num int) {
 	minTransactionsPerBlock = num
 }
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the number of transactions per block
+ * @param: instance of blockchain
+ * @return: int
+ **/
+
+func (bc *Blockchain) GetNumberOfTransactionsPerBlock() int {
+	return minTransactionsPerBlock
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the hash of the last block, it is empty if there are no blocks
+ * @param: instance of blockchain
+ * @return: hash of the last block
+ **/
+
+func (bc *Blockchain) LatestHash() string {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.latestHashLocked()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the hash of the last block, it must be called with the lock held
+ * @return: hash of the last block
+ **/
+
+func (bc *Blockchain) latestHashLocked() string {
+	if len(bc.blocks) == 0 {
+		return ""
+	}
+	return bc.blocks[len(bc.blocks)-1].CurrentHash
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the transaction is already included in any block
+ * @param: transaction string
+ * @return: bool
+ **/
+
+func (bc *Blockchain) ContainsTransaction(transaction string) bool {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	for _, block := range bc.blocks {
+		for _, tx := range block.Transactions {
+			if tx == transaction {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if a transaction in any block already uses the sender nonce or the inputs of the given transaction
+ * @description: A transaction in a block which claims a sender without its signature uses nothing, it cannot block a nonce.
+ * @param: instance of transaction
+ * @return: bool
+ **/
+
+func (bc *Blockchain) HasConflictingTransaction(tx *Transaction) bool {
+	if !tx.Replaceable() {
+		return false
+	}
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	for _, block := range bc.blocks {
+		for _, raw := range block.Transactions {
+			other := ParseTransaction(raw)
+			if other.Sender != "" && !other.Signed() {
+				continue
+			}
+			if tx.ConflictsWith(other) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package MidLevelBlockchain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testMiners = 3       // Goroutines mining on the same chain at the same time
+const testMineAttempts = 4 // Blocks each miner tries to add, the difficulty grows with the height so the chain stays short
+const testReaders = 4      // Goroutines reading the chain while it changes
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create an empty blockchain in memory for a test
+ * @param: testing instance
+ * @return: instance of blockchain
+ **/
+
+func newTestBlockchain(t *testing.T) *Blockchain {
+	t.Helper()
+	bc, err := NewBlockchain(NewMemoryBlockStore())
+	if err != nil {
+		t.Fatalf("creating the blockchain: %v", err)
+	}
+	return bc
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read the chain in a loop, as the display, verification, sync and gossip paths do,
+ * @description: until done is closed
+ * @param: instance of blockchain, done channel, wait group of the readers
+ **/
+
+func readChain(bc *Blockchain, done chan struct{}, readers *sync.WaitGroup) {
+	defer readers.Done()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		snapshot := bc.Snapshot()
+		bc.VerifyChain()
+		bc.BlocksAfter(bc.Locator(), "", 64)
+		if len(snapshot) > 0 {
+			bc.HeightOf(snapshot[len(snapshot)-1].CurrentHash)
+			bc.ContainsTransaction(strings.Join(snapshot[0].Transactions, ""))
+		}
+		bc.Tip()
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check that every block of the blocks links to the one before it
+ * @param: testing instance, slice of blocks
+ **/
+
+func checkLinks(t *testing.T, blocks []*Block) {
+	t.Helper()
+	for i := 1; i < len(blocks); i++ {
+		if blocks[i].PreviousHash != blocks[i-1].CurrentHash {
+			t.Fatalf("block %d links to %s, want %s", i, blocks[i].PreviousHash, blocks[i-1].CurrentHash)
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that miners racing for the same tip while the chain is read keep a single valid chain,
+ * @description: a block mined on a tip which changed meanwhile is refused instead of forking the chain
+ **/
+
+func TestConcurrentMining(t *testing.T) {
+	bc := newTestBlockchain(t)
+
+	var miners, readers sync.WaitGroup
+	var mu sync.Mutex
+	mined := 0
+	done := make(chan struct{})
+	for r := 0; r < testReaders; r++ {
+		readers.Add(1)
+		go readChain(bc, done, &readers)
+	}
+	for m := 0; m < testMiners; m++ {
+		miners.Add(1)
+		go func(m int) {
+			defer miners.Done()
+			for i := 0; i < testMineAttempts; i++ {
+				if bc.ForceMineBlock([]string{fmt.Sprintf("miner %d transaction %d", m, i)}, bc.LatestHash()) != nil {
+					mu.Lock()
+					mined++
+					mu.Unlock()
+				}
+			}
+		}(m)
+	}
+	miners.Wait()
+	close(done)
+	readers.Wait()
+
+	if mined == 0 {
+		t.Fatal("no block was mined")
+	}
+	if bc.Height() != mined {
+		t.Errorf("chain height is %d, %d blocks were added", bc.Height(), mined)
+	}
+	checkLinks(t, bc.Snapshot())
+	if !bc.VerifyChain() {
+		t.Error("the chain does not verify")
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that blocks changed while the chain is read keep the chain linked and are detected by
+ * @description: the verification, while the snapshots taken before keep the original blocks
+ **/
+
+func TestChangeBlockWhileReading(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for i := 0; i < 6; i++ {
+		if bc.ForceMineBlock([]string{fmt.Sprintf("transaction %d", i)}, bc.LatestHash()) == nil {
+			t.Fatalf("mining block %d failed", i)
+		}
+	}
+	before := bc.Snapshot()
+
+	var writers, readers sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < testReaders; r++ {
+		readers.Add(1)
+		go readChain(bc, done, &readers)
+	}
+	for _, height := range []int{1, 3, 4} {
+		writers.Add(1)
+		go func(height int) {
+			defer writers.Done()
+			bc.ChangeBlock(height, fmt.Sprintf("changed transaction %d", height))
+		}(height)
+	}
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	if bc.Height() != len(before) {
+		t.Errorf("chain height is %d after the changes, want %d", bc.Height(), len(before))
+	}
+	checkLinks(t, bc.Snapshot())
+	if bc.VerifyChain() {
+		t.Error("the changed chain still verifies")
+	}
+	for i, block := range before {
+		var previous *Block
+		if i > 0 {
+			previous = before[i-1]
+		}
+		if !VerifyBlock(block, previous) {
+			t.Fatalf("block %d of the snapshot taken before the changes was modified", i)
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that the mining stops once its context is done, instead of searching for the nonce
+ * @description: until it is found
+ **/
+
+func TestMiningCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if block := mineBlock(ctx, []string{"transaction"}, strings.Repeat("0", 64), 64); block != nil {
+		t.Fatalf("cancelled mining returned a block with nonce %d", block.Nonce)
+	}
+}
@@ -0,0 +1,160 @@
+package MidLevelBlockchain
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrBlockNotFound = errors.New("block not found")
+
+// BlockStore is the storage backend of the blockchain, blocks are appended in height order.
+type BlockStore interface {
+	Append(block *Block) error                     // Persist the block on top of the current tip
+	Blocks() ([]*Block, error)                     // All the blocks in height order
+	BlockByHash(hash string) (*Block, error)       // ErrBlockNotFound if unknown
+	BlockByHeight(height int) (*Block, error)      // ErrBlockNotFound if out of range
+	Height() int                                   // Number of stored blocks
+	Prune(below int) error                         // Replace the blocks below the height by their headers
+	ReplaceFrom(height int, blocks []*Block) error // Replace the blocks from the height on by the given blocks
+	Close() error
+}
+
+// MemoryBlockStore keeps the blocks in memory only, it is lost when the node exits.
+type MemoryBlockStore struct {
+	mu     sync.RWMutex
+	blocks []*Block
+	byHash map[string]int
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the new in-memory block store
+ * @return: instance of memory block store
+ **/
+
+func NewMemoryBlockStore() *MemoryBlockStore {
+	return &MemoryBlockStore{byHash: make(map[string]int)}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to append the block to the store
+ * @param: instance of block
+ * @return: error if any
+ **/
+
+func (s *MemoryBlockStore) Append(block *Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byHash[block.CurrentHash] = len(s.blocks)
+	s.blocks = append(s.blocks, block)
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get all the blocks of the store in height order
+ * @return: slice of blocks and error if any
+ **/
+
+func (s *MemoryBlockStore) Blocks() ([]*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*Block(nil), s.blocks...), nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the block by its hash
+ * @param: hash string
+ * @return: instance of block and error if any
+ **/
+
+func (s *MemoryBlockStore) BlockByHash(hash string) (*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	height, ok := s.byHash[hash]
+	if !ok {
+		return nil, ErrBlockNotFound
+	}
+	return s.blocks[height], nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the block by its height
+ * @param: height int
+ * @return: instance of block and error if any
+ **/
+
+func (s *MemoryBlockStore) BlockByHeight(height int) (*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if height < 0 || height >= len(s.blocks) {
+		return nil, ErrBlockNotFound
+	}
+	return s.blocks[height], nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the number of blocks in the store
+ * @return: int
+ **/
+
+func (s *MemoryBlockStore) Height() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.blocks)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to delete the transactions of the blocks below the height, only their headers are kept
+ * @param: below int height
+ * @return: error if any
+ **/
+
+func (s *MemoryBlockStore) Prune(below int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for height := 0; height < below && height < len(s.blocks); height++ {
+		if !s.blocks[height].Pruned {
+			s.blocks[height] = s.blocks[height].Header()
+		}
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to replace the blocks from the height on by the given blocks
+ * @param: height int of the first replaced block, slice of the new blocks
+ * @return: error if any
+ **/
+
+func (s *MemoryBlockStore) ReplaceFrom(height int, blocks []*Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if height < 0 || height > len(s.blocks) {
+		return ErrBlockNotFound
+	}
+	for _, block := range s.blocks[height:] {
+		delete(s.byHash, block.CurrentHash)
+	}
+	s.blocks = append(s.blocks[:height:height], blocks...)
+	for i, block := range blocks {
+		s.byHash[block.CurrentHash] = height + i
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to close the store, there is nothing to release for the memory store
+ * @return: error if any
+ **/
+
+func (s *MemoryBlockStore) Close() error {
+	return nil
+}
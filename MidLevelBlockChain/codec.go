@@ -0,0 +1,160 @@
+package MidLevelBlockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var ErrInvalidEncoding = errors.New("invalid binary encoding")
+
+const MaxEncodedLength = 16 << 20 // Longest length prefix which is decoded, it guards against a garbage length
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the block in the compact binary format
+ * @description: Every field is written in order, strings are prefixed by their length as a varint, so the encoding is deterministic.
+ * @param: instance of block
+ * @return: byte slice and error if any
+ **/
+
+func (b *Block) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	WriteUvarint(&buf, uint64(len(b.Transactions)))
+	for _, tx := range b.Transactions {
+		WriteString(&buf, tx)
+	}
+	WriteVarint(&buf, int64(b.Nonce))
+	WriteString(&buf, b.PreviousHash)
+	WriteString(&buf, b.CurrentHash)
+	WriteString(&buf, b.MerkleRoot)
+	if b.Pruned {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	return buf.Bytes(), nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to decode the block from the compact binary format
+ * @param: byte slice of the encoded block
+ * @return: error if any
+ **/
+
+func (b *Block) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil || count > uint64(len(data)) {
+		return ErrInvalidEncoding
+	}
+	var transactions []string
+	for i := uint64(0); i < count; i++ {
+		tx, err := ReadString(r)
+		if err != nil {
+			return err
+		}
+		transactions = append(transactions, tx)
+	}
+	nonce, err := binary.ReadVarint(r)
+	if err != nil {
+		return ErrInvalidEncoding
+	}
+
+	var fields [3]string
+	for i := range fields {
+		if fields[i], err = ReadString(r); err != nil {
+			return err
+		}
+	}
+	pruned, err := r.ReadByte()
+	if err != nil || pruned > 1 || r.Len() != 0 {
+		return ErrInvalidEncoding
+	}
+
+	*b = Block{
+		Transactions: transactions,
+		Nonce:        int(nonce),
+		PreviousHash: fields[0],
+		CurrentHash:  fields[1],
+		MerkleRoot:   fields[2],
+		Pruned:       pruned == 1,
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: These functions are used to write the primitive values of the binary format, they are shared with the
+ * @description: binary codec of the network
+ * @param: buffer and the value
+ **/
+
+func WriteUvarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+func WriteVarint(buf *bytes.Buffer, v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutVarint(tmp[:], v)])
+}
+
+func WriteBytes(buf *bytes.Buffer, data []byte) {
+	WriteUvarint(buf, uint64(len(data)))
+	buf.Write(data)
+}
+
+func WriteString(buf *bytes.Buffer, s string) {
+	WriteUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read a length of the binary format, it cannot exceed the data left
+ * @param: reader of the encoded data
+ * @return: length and error if it is invalid
+ **/
+
+func ReadLength(r *bytes.Reader) (int, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil || length > MaxEncodedLength || length > uint64(r.Len()) {
+		return 0, ErrInvalidEncoding
+	}
+	return int(length), nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read a length prefixed byte slice of the binary format
+ * @param: reader of the encoded data
+ * @return: byte slice and error if any
+ **/
+
+func ReadBytes(r *bytes.Reader) ([]byte, error) {
+	length, err := ReadLength(r)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, ErrInvalidEncoding
+	}
+	return data, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read a length prefixed string of the binary format
+ * @param: reader of the encoded data
+ * @return: string and error if any
+ **/
+
+func ReadString(r *bytes.Reader) (string, error) {
+	data, err := ReadBytes(r)
+	return string(data), err
+}
@@ -0,0 +1,528 @@
+package MidLevelBlockchain
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const blockFileName = "blocks.dat"  // Append-only file with the raw block records
+const indexFileName = "index.json"  // Index of the committed records by height and hash
+const recordHeaderSize = 8          // 4 bytes payload length + 4 bytes CRC32 of the payload
+const maxBlockRecordSize = 32 << 20 // Guards against reading a garbage length
+
+var ErrCorruptRecord = errors.New("corrupt block record")
+
+// FileBlockStore keeps the blocks on disk in an append-only block file plus an index by height and hash.
+// The block file is the source of truth: when the store is opened it is scanned, a torn record at the end
+// (a crash in the middle of an append) is truncated and the index is rebuilt if it does not match.
+type FileBlockStore struct {
+	mu      sync.RWMutex
+	dir     string
+	file    *os.File
+	entries []indexEntry // Indexed by height
+	byHash  map[string]int
+}
+
+// indexEntry locates a block record inside the block file.
+type indexEntry struct {
+	Hash   string
+	Offset int64
+	Size   int64 // Size of the whole record including its header
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to open the on-disk block store in the given directory, it is created if needed
+ * @param: dir string of the data directory
+ * @return: instance of file block store and error if any
+ **/
+
+func OpenFileBlockStore(dir string) (*FileBlockStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, blockFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileBlockStore{dir: dir, file: file, byHash: make(map[string]int)}
+	report, err := s.Recover()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if report.TruncatedBytes > 0 || report.IndexRebuilt {
+		fmt.Printf("Block store recovered: %d valid block(s), truncated %d byte(s), index rebuilt: %t\n",
+			report.ValidBlocks, report.TruncatedBytes, report.IndexRebuilt)
+	}
+	return s, nil
+}
+
+// RecoveryReport describes what the consistency check of the block store repaired.
+type RecoveryReport struct {
+	ValidBlocks    int   // Blocks kept in the store
+	TruncatedBytes int64 // Bytes of torn or invalid records cut from the end of the block file
+	IndexRebuilt   bool  // The index was missing or did not match the block file and was rebuilt, not set for a new store
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check the consistency of the block store after a crash or power loss
+ * @description: The block file is scanned up to the first torn or invalid record, everything from there on is
+ * @description: truncated and the index is rebuilt from the valid records if it does not match them.
+ * @return: recovery report and error if any
+ **/
+
+func (s *FileBlockStore) Recover() (*RecoveryReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, validSize, err := scanBlockFile(s.file)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RecoveryReport{ValidBlocks: len(entries), TruncatedBytes: info.Size() - validSize}
+	if report.TruncatedBytes > 0 {
+		if err := s.file.Truncate(validSize); err != nil {
+			return nil, err
+		}
+		if err := s.file.Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !s.indexMatches(entries) {
+		_, statErr := os.Stat(filepath.Join(s.dir, indexFileName))
+		fresh := os.IsNotExist(statErr) && len(entries) == 0 && report.TruncatedBytes == 0
+		if err := s.writeIndex(entries); err != nil {
+			return nil, err
+		}
+		report.IndexRebuilt = !fresh // A new store only gets its first index, nothing was repaired
+	}
+
+	s.entries = entries
+	s.byHash = make(map[string]int)
+	for height, entry := range entries {
+		s.byHash[entry.Hash] = height
+	}
+	return report, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to rebuild the index from the raw block data, the old index is thrown away
+ * @return: recovery report and error if any
+ **/
+
+func (s *FileBlockStore) Reindex() (*RecoveryReport, error) {
+	s.mu.Lock()
+	err := os.Remove(filepath.Join(s.dir, indexFileName))
+	s.mu.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return s.Recover()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the index file on disk matches the scanned records
+ * @param: entries of the scanned records
+ * @return: bool
+ **/
+
+func (s *FileBlockStore) indexMatches(entries []indexEntry) bool {
+	data, err := os.ReadFile(filepath.Join(s.dir, indexFileName))
+	if err != nil {
+		return false
+	}
+	var indexed []indexEntry
+	if err := json.Unmarshal(data, &indexed); err != nil || len(indexed) != len(entries) {
+		return false
+	}
+	for i := range entries {
+		if indexed[i] != entries[i] {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to scan the block file from the start until the first torn or invalid record
+ * @description: A record is valid if it is complete, its checksum matches and it links to the previous block.
+ * @param: file of the blocks
+ * @return: index entries of the valid records, size of the valid part of the file and error if any
+ **/
+
+func scanBlockFile(file *os.File) ([]indexEntry, int64, error) {
+	var entries []indexEntry
+	var offset int64
+	previousHash := ""
+
+	for {
+		block, size, err := readBlockRecord(file, offset)
+		if err == io.EOF || err == io.ErrUnexpectedEOF || errors.Is(err, ErrCorruptRecord) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(entries) > 0 && block.PreviousHash != previousHash {
+			break
+		}
+
+		entries = append(entries, indexEntry{Hash: block.CurrentHash, Offset: offset, Size: size})
+		previousHash = block.CurrentHash
+		offset += size
+	}
+	return entries, offset, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the size of the block file which is referenced by the index
+ * @return: size in bytes
+ **/
+
+func (s *FileBlockStore) committedSize() int64 {
+	if len(s.entries) == 0 {
+		return 0
+	}
+	last := s.entries[len(s.entries)-1]
+	return last.Offset + last.Size
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to append the block to the block file and commit it in the index
+ * @description: The record is synced to disk before the index is atomically replaced, so a crash at any
+ * @description: point leaves either the old chain or the new chain, never a corrupt one.
+ * @param: instance of block
+ * @return: error if any
+ **/
+
+func (s *FileBlockStore) Append(block *Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := encodeBlockRecord(block)
+	if err != nil {
+		return err
+	}
+
+	offset := s.committedSize()
+	if _, err := s.file.WriteAt(record, offset); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+
+	entries := append(s.entries, indexEntry{Hash: block.CurrentHash, Offset: offset, Size: int64(len(record))})
+	if err := s.writeIndex(entries); err != nil {
+		return err
+	}
+	s.entries = entries
+	s.byHash[block.CurrentHash] = len(entries) - 1
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to replace the index file atomically
+ * @param: entries of the index
+ * @return: error if any
+ **/
+
+func (s *FileBlockStore) writeIndex(entries []indexEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(s.dir, indexFileName), data)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get all the blocks of the store in height order
+ * @return: slice of blocks and error if any
+ **/
+
+func (s *FileBlockStore) Blocks() ([]*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blocks := make([]*Block, 0, len(s.entries))
+	for height := range s.entries {
+		block, err := s.readBlock(height)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the block by its hash
+ * @param: hash string
+ * @return: instance of block and error if any
+ **/
+
+func (s *FileBlockStore) BlockByHash(hash string) (*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	height, ok := s.byHash[hash]
+	if !ok {
+		return nil, ErrBlockNotFound
+	}
+	return s.readBlock(height)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the block by its height
+ * @param: height int
+ * @return: instance of block and error if any
+ **/
+
+func (s *FileBlockStore) BlockByHeight(height int) (*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if height < 0 || height >= len(s.entries) {
+		return nil, ErrBlockNotFound
+	}
+	return s.readBlock(height)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the number of blocks in the store
+ * @return: int
+ **/
+
+func (s *FileBlockStore) Height() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to delete the transactions of the blocks below the height, only their headers are kept
+ * @param: below int height
+ * @return: error if any
+ **/
+
+func (s *FileBlockStore) Prune(below int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blocks := make([]*Block, 0, len(s.entries))
+	for height := range s.entries {
+		block, err := s.readBlock(height)
+		if err != nil {
+			return err
+		}
+		if height < below && !block.Pruned {
+			block = block.Header()
+		}
+		blocks = append(blocks, block)
+	}
+	return s.rewrite(blocks)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to replace the blocks from the height on by the given blocks
+ * @param: height int of the first replaced block, slice of the new blocks
+ * @return: error if any
+ **/
+
+func (s *FileBlockStore) ReplaceFrom(height int, replacements []*Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if height < 0 || height > len(s.entries) {
+		return ErrBlockNotFound
+	}
+
+	blocks := make([]*Block, 0, height+len(replacements))
+	for h := 0; h < height; h++ {
+		block, err := s.readBlock(h)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, block)
+	}
+	return s.rewrite(append(blocks, replacements...))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to write the blocks as the new content of the store, it must be called with the lock held
+ * @description: The block file is rewritten into a temporary file which atomically replaces it, then the index is rebuilt.
+ * @description: The open block file is closed for the rename, which Windows refuses on an open file, and reopened
+ * @description: afterwards, the old one if the rename failed.
+ * @param: slice of all the blocks in height order
+ * @return: error if any
+ **/
+
+func (s *FileBlockStore) rewrite(blocks []*Block) error {
+	path := filepath.Join(s.dir, blockFileName)
+	tmp, err := os.CreateTemp(s.dir, blockFileName+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once the rename succeeded
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	var entries []indexEntry
+	var offset int64
+	for _, block := range blocks {
+		record, err := encodeBlockRecord(block)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if _, err := tmp.Write(record); err != nil {
+			tmp.Close()
+			return err
+		}
+		entries = append(entries, indexEntry{Hash: block.CurrentHash, Offset: offset, Size: int64(len(record))})
+		offset += int64(len(record))
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// The old index does not match the new block file, a crash before the index is written is repaired on open
+	s.file.Close()
+	renameErr := os.Rename(tmp.Name(), path)
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return errors.Join(renameErr, err)
+	}
+	s.file = file
+	if renameErr != nil {
+		return renameErr
+	}
+	s.entries = entries
+	s.byHash = make(map[string]int)
+	for height, entry := range entries {
+		s.byHash[entry.Hash] = height
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+	return s.writeIndex(entries)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to flush the block file to the disk and close it
+ * @return: error if any
+ **/
+
+func (s *FileBlockStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read the block at the given height from the block file
+ * @param: height int
+ * @return: instance of block and error if any
+ **/
+
+func (s *FileBlockStore) readBlock(height int) (*Block, error) {
+	entry := s.entries[height]
+	block, size, err := readBlockRecord(s.file, entry.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("block %d: %w", height, err)
+	}
+	if size != entry.Size || block.CurrentHash != entry.Hash {
+		return nil, fmt.Errorf("block %d: %w: does not match the index", height, ErrCorruptRecord)
+	}
+	return block, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the block into a record of the block file
+ * @param: instance of block
+ * @return: record bytes and error if any
+ **/
+
+func encodeBlockRecord(block *Block) ([]byte, error) {
+	payload, err := json.Marshal(block)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[recordHeaderSize:], payload)
+	return record, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read and check the block record at the given offset of the block file
+ * @param: r io.ReaderAt of the block file, offset int64
+ * @return: instance of block, size of the record and error if any
+ **/
+
+func readBlockRecord(r io.ReaderAt, offset int64) (*Block, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length == 0 || length > maxBlockRecordSize {
+		return nil, 0, ErrCorruptRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := r.ReadAt(payload, offset+recordHeaderSize); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, ErrCorruptRecord
+	}
+
+	var block Block
+	if err := json.Unmarshal(payload, &block); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrCorruptRecord, err)
+	}
+	return &block, recordHeaderSize + int64(length), nil
+}
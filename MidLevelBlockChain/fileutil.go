@@ -0,0 +1,67 @@
+package MidLevelBlockchain
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to write the file atomically, the data is written to a temporary file
+ * @description: which is synced and then renamed over the target, so a crash never leaves a half written file.
+ * @description: The directory is synced after the rename, so the new file survives a crash.
+ * @param: path string of the file, data []byte
+ * @return: error if any
+ **/
+
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once the rename succeeded
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to flush the entries of the directory to the disk, so a file renamed into it is
+ * @description: still there after a crash. Windows cannot open a directory for syncing, the sync is skipped there.
+ * @param: path string of the directory
+ * @return: error if any
+ **/
+
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
@@ -0,0 +1,378 @@
+package MidLevelBlockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	ErrEmptyTransaction     = errors.New("transaction cannot be empty")
+	ErrDuplicateTransaction = errors.New("transaction is already in the mempool")
+	ErrTransactionInChain   = errors.New("transaction is already included in the blockchain")
+	ErrConflictInChain      = errors.New("transaction nonce or inputs are already used in the blockchain")
+	ErrNegativeFee          = errors.New("transaction fee cannot be negative")
+	ErrReplacementFeeTooLow = errors.New("replacement fee is not high enough to replace the pending transaction")
+	ErrInvalidSignature     = errors.New("transaction signature does not match its sender")
+	ErrTransactionNotSigned = errors.New("transaction with a sender must be signed by it")
+	ErrReplacementNotSigned = errors.New("only a transaction signed by the sender of the pending transaction can replace it")
+)
+
+// A replacement must pay at least this many percent more than the fees of all the transactions it replaces
+const replacementFeeBumpPercent = 10
+
+// Mempool holds the valid transactions which are waiting to be mined into a block.
+type Mempool struct {
+	mu         sync.Mutex
+	blockchain *Blockchain
+	entries    []*mempoolEntry // Kept in arrival order
+	byHash     map[string]*mempoolEntry
+}
+
+type mempoolEntry struct {
+	tx      *Transaction
+	hash    string
+	addedAt time.Time
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the new mempool for the given blockchain
+ * @param: instance of blockchain against which the transactions are validated
+ * @return: instance of mempool
+ **/
+
+func NewMempool(bc *Blockchain) *Mempool {
+	return &Mempool{
+		blockchain: bc,
+		byHash:     make(map[string]*mempoolEntry),
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add the transaction to the mempool after validating it
+ * @description: If it conflicts with pending transactions (same sender nonce or same inputs) it replaces them, as long as
+ * @description: it pays a sufficiently higher fee (replace-by-fee). A pending transaction with a sender is only replaced
+ * @description: by a transaction of the same sender; one with inputs and no sender has no owner, any spender may replace it.
+ * @param: instance of transaction
+ * @return: the replaced transactions and error if the transaction is not valid
+ **/
+
+func (mp *Mempool) AddTransaction(tx *Transaction) ([]*Transaction, error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if err := mp.validate(tx); err != nil {
+		return nil, err
+	}
+
+	conflicts := mp.conflicting(tx)
+	if len(conflicts) > 0 {
+		replacedFees := 0
+		for _, entry := range conflicts {
+			// Transactions with a sender, pending or not, are signed by it, validate checked it
+			if entry.tx.Sender != "" && entry.tx.Sender != tx.Sender {
+				return nil, ErrReplacementNotSigned
+			}
+			replacedFees += entry.tx.Fee
+		}
+		if tx.Fee*100 < replacedFees*(100+replacementFeeBumpPercent) || tx.Fee <= replacedFees {
+			return nil, ErrReplacementFeeTooLow
+		}
+	}
+
+	var replaced []*Transaction
+	for _, entry := range conflicts {
+		mp.removeEntry(entry.hash)
+		replaced = append(replaced, entry.tx)
+	}
+
+	entry := &mempoolEntry{tx: tx, hash: tx.Hash(), addedAt: time.Now()}
+	mp.entries = append(mp.entries, entry)
+	mp.byHash[entry.hash] = entry
+	return replaced, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to find the pending transactions which conflict with the given transaction
+ * @param: instance of transaction
+ * @return: slice of mempool entries
+ **/
+
+func (mp *Mempool) conflicting(tx *Transaction) []*mempoolEntry {
+	var conflicts []*mempoolEntry
+	if !tx.Replaceable() {
+		return conflicts
+	}
+	for _, entry := range mp.entries {
+		if tx.ConflictsWith(entry.tx) {
+			conflicts = append(conflicts, entry)
+		}
+	}
+	return conflicts
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to remove the transaction with the given hash from the mempool
+ * @param: hash string of the transaction
+ **/
+
+func (mp *Mempool) removeEntry(hash string) {
+	if _, ok := mp.byHash[hash]; !ok {
+		return
+	}
+	delete(mp.byHash, hash)
+	for i, entry := range mp.entries {
+		if entry.hash == hash {
+			mp.entries = append(mp.entries[:i], mp.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to find a pending transaction by its hash or a prefix of its hash
+ * @param: hash prefix string
+ * @return: instance of transaction, nil if none or more than one transaction matches
+ **/
+
+func (mp *Mempool) FindTransaction(hashPrefix string) *Transaction {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if hashPrefix == "" {
+		return nil
+	}
+	var found *Transaction
+	for _, entry := range mp.entries {
+		if strings.HasPrefix(entry.hash, hashPrefix) {
+			if found != nil {
+				return nil
+			}
+			found = entry.tx
+		}
+	}
+	return found
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the transaction can be accepted into the mempool
+ * @param: instance of transaction
+ * @return: error if the transaction is not valid
+ **/
+
+func (mp *Mempool) validate(tx *Transaction) error {
+	if tx == nil || len(tx.Data) == 0 {
+		return ErrEmptyTransaction
+	}
+	if tx.Fee < 0 {
+		return ErrNegativeFee
+	}
+	if tx.Sender != "" && tx.Signature == "" {
+		return ErrTransactionNotSigned // Anyone could otherwise take the nonce of the sender
+	}
+	if tx.Signature != "" && !tx.Signed() {
+		return ErrInvalidSignature
+	}
+	if _, ok := mp.byHash[tx.Hash()]; ok {
+		return ErrDuplicateTransaction
+	}
+	if mp.blockchain != nil && mp.blockchain.ContainsTransaction(tx.Encode()) {
+		return ErrTransactionInChain
+	}
+	if mp.blockchain != nil && mp.blockchain.HasConflictingTransaction(tx) {
+		return ErrConflictInChain
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the number of transactions waiting in the mempool
+ * @return: int
+ **/
+
+func (mp *Mempool) Count() int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return len(mp.entries)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get how long the oldest transaction has been waiting in the mempool
+ * @return: duration, zero if the mempool is empty
+ **/
+
+func (mp *Mempool) OldestAge() time.Duration {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if len(mp.entries) == 0 {
+		return 0
+	}
+	return time.Since(mp.entries[0].addedAt)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the pending transactions in the order they arrived
+ * @return: slice of transactions
+ **/
+
+func (mp *Mempool) PendingTransactions() []*Transaction {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	txs := make([]*Transaction, 0, len(mp.entries))
+	for _, entry := range mp.entries {
+		txs = append(txs, entry.tx)
+	}
+	return txs
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to remove the transactions which are included in the block from the mempool
+ * @param: instance of block
+ **/
+
+func (mp *Mempool) RemoveBlockTransactions(block *Block) {
+	if block == nil {
+		return
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	var included []*Transaction
+	for _, raw := range block.Transactions {
+		included = append(included, ParseTransaction(raw))
+	}
+
+	// Drop the mined transactions and the pending ones which conflict with them
+	kept := mp.entries[:0]
+	for _, entry := range mp.entries {
+		drop := false
+		for _, tx := range included {
+			if tx.Hash() == entry.hash || (tx.Replaceable() && tx.ConflictsWith(entry.tx)) {
+				drop = true
+				break
+			}
+		}
+		if drop {
+			delete(mp.byHash, entry.hash)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	mp.entries = kept
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to display the pending transactions in a tabular format
+ * @param: instance of mempool
+ **/
+
+func (mp *Mempool) DisplayTransactions() {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tHash\tSender\tNonce\tFee\tTransaction\tWaiting")
+	for i, entry := range mp.entries {
+		waiting := time.Since(entry.addedAt).Round(time.Second)
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%s\n", i, limitHashDisplay(entry.hash, 16), limitHashDisplay(entry.tx.Sender, 16), entry.tx.Nonce, entry.tx.Fee, entry.tx.Data, waiting)
+	}
+	w.Flush()
+}
+
+// persistedTransaction is the form in which a pending transaction is saved to disk.
+type persistedTransaction struct {
+	Transaction *Transaction
+	AddedAt     time.Time
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to save the pending transactions to the file, the file is replaced atomically
+ * @param: path string of the file
+ * @return: error if any
+ **/
+
+func (mp *Mempool) SaveToFile(path string) error {
+	mp.mu.Lock()
+	persisted := make([]persistedTransaction, 0, len(mp.entries))
+	for _, entry := range mp.entries {
+		persisted = append(persisted, persistedTransaction{Transaction: entry.tx, AddedAt: entry.addedAt})
+	}
+	mp.mu.Unlock()
+
+	data, err := json.MarshalIndent(persisted, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to load the pending transactions from the file
+ * @description: Every transaction is revalidated against the current chain, the ones which became invalid are dropped.
+ * @param: path string of the file
+ * @return: number of loaded and dropped transactions, error if the file cannot be read (a missing file is not an error)
+ **/
+
+func (mp *Mempool) LoadFromFile(path string) (int, int, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var persisted []persistedTransaction
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return 0, 0, err
+	}
+
+	loaded, dropped := 0, 0
+	for _, p := range persisted {
+		if p.Transaction == nil {
+			dropped++
+			continue
+		}
+		if _, err := mp.AddTransaction(p.Transaction); err != nil {
+			fmt.Printf("Dropping pending transaction %s: %v\n", p.Transaction.Hash(), err)
+			dropped++
+			continue
+		}
+		mp.restoreAddedAt(p.Transaction.Hash(), p.AddedAt)
+		loaded++
+	}
+	return loaded, dropped, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to restore the time at which a reloaded transaction first entered the mempool
+ * @param: hash string of the transaction, addedAt time.Time
+ **/
+
+func (mp *Mempool) restoreAddedAt(hash string, addedAt time.Time) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if entry, ok := mp.byHash[hash]; ok && !addedAt.IsZero() {
+		entry.addedAt = addedAt
+	}
+}
@@ -0,0 +1,122 @@
+package MidLevelBlockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create a signing key for a test
+ * @param: testing instance
+ * @return: ed25519 private key
+ **/
+
+func newTestKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create a transaction signed by the key
+ * @param: key of the sender, nonce, fee, inputs, data
+ * @return: instance of transaction
+ **/
+
+func signedTransaction(key ed25519.PrivateKey, nonce int, fee int, inputs []string, data string) *Transaction {
+	tx := &Transaction{Nonce: nonce, Fee: fee, Inputs: inputs, Data: data}
+	tx.Sign(key)
+	return tx
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that a transaction claiming a sender without its signature is refused, in the mempool
+ * @description: and in a block, so it cannot take the nonce of the sender before the real transaction
+ **/
+
+func TestUnsignedSenderCannotTakeNonce(t *testing.T) {
+	bc := newTestBlockchain(t)
+	mp := NewMempool(bc)
+	victim := newTestKey(t)
+
+	forged := &Transaction{Sender: hex.EncodeToString(victim.Public().(ed25519.PublicKey)), Nonce: 1, Fee: 50, Data: "forged"}
+	if _, err := mp.AddTransaction(forged); !errors.Is(err, ErrTransactionNotSigned) {
+		t.Fatalf("adding the unsigned transaction returned %v, want %v", err, ErrTransactionNotSigned)
+	}
+
+	// A miner may still put it in a block, it must not use the nonce of the victim
+	if bc.ForceMineBlock([]string{forged.Encode()}, bc.LatestHash()) == nil {
+		t.Fatal("mining the block failed")
+	}
+	if _, err := mp.AddTransaction(signedTransaction(victim, 1, 1, nil, "real")); err != nil {
+		t.Fatalf("the signed transaction of the sender was refused: %v", err)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that a pending transaction with a sender is only replaced by the same sender, with a
+ * @description: high enough fee
+ **/
+
+func TestReplacementBySender(t *testing.T) {
+	mp := NewMempool(newTestBlockchain(t))
+	sender, other := newTestKey(t), newTestKey(t)
+	original := signedTransaction(sender, 1, 10, []string{"output 1"}, "original")
+	if _, err := mp.AddTransaction(original); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mp.AddTransaction(signedTransaction(other, 1, 100, []string{"output 1"}, "other sender")); !errors.Is(err, ErrReplacementNotSigned) {
+		t.Fatalf("replacement by another sender returned %v, want %v", err, ErrReplacementNotSigned)
+	}
+	if _, err := mp.AddTransaction(signedTransaction(sender, 1, 10, nil, "same fee")); !errors.Is(err, ErrReplacementFeeTooLow) {
+		t.Fatalf("replacement with the same fee returned %v, want %v", err, ErrReplacementFeeTooLow)
+	}
+	replaced, err := mp.AddTransaction(signedTransaction(sender, 1, 20, nil, "replacement"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replaced) != 1 || replaced[0].Hash() != original.Hash() {
+		t.Fatalf("replacement replaced %d transactions, want the original", len(replaced))
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that a pending transaction which only spends inputs, without a sender, is replaced
+ * @description: by any transaction spending one of its inputs which pays a high enough fee
+ **/
+
+func TestInputConflictReplacedByFee(t *testing.T) {
+	mp := NewMempool(newTestBlockchain(t))
+	original := &Transaction{Fee: 10, Inputs: []string{"output 1", "output 2"}, Data: "original"}
+	if _, err := mp.AddTransaction(original); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mp.AddTransaction(&Transaction{Fee: 10, Inputs: []string{"output 2"}, Data: "same fee"}); !errors.Is(err, ErrReplacementFeeTooLow) {
+		t.Fatalf("replacement with the same fee returned %v, want %v", err, ErrReplacementFeeTooLow)
+	}
+	replacement := signedTransaction(newTestKey(t), 1, 20, []string{"output 2"}, "replacement")
+	replaced, err := mp.AddTransaction(replacement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replaced) != 1 || replaced[0].Hash() != original.Hash() {
+		t.Fatalf("replacement replaced %d transactions, want the original", len(replaced))
+	}
+
+	// The replacement has a sender now, so only that sender replaces it
+	if _, err := mp.AddTransaction(&Transaction{Fee: 100, Inputs: []string{"output 2"}, Data: "unsigned"}); !errors.Is(err, ErrReplacementNotSigned) {
+		t.Fatalf("unsigned replacement of a signed transaction returned %v, want %v", err, ErrReplacementNotSigned)
+	}
+}
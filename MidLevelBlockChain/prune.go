@@ -0,0 +1,102 @@
+package MidLevelBlockchain
+
+// Pruning is done in batches so the block store is not rewritten for every new block
+const pruneBatchSize = 10
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the difficulty which the block at the given height was mined with
+ * @param: height int
+ * @return: difficulty int
+ **/
+
+func difficultyAt(height int) int {
+	return initialDifficulty + (height / difficultyAdjustmentInterval)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the height below which the blocks are pruned, i.e. the number of leading pruned blocks
+ * @param: instance of blockchain
+ * @return: int, 0 if no block is pruned
+ **/
+
+func (bc *Blockchain) PrunedHeight() int {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.prunedHeightLocked()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the pruned height, it must be called with the lock held
+ * @return: int
+ **/
+
+func (bc *Blockchain) prunedHeightLocked() int {
+	for height, block := range bc.blocks {
+		if !block.Pruned {
+			return height
+		}
+	}
+	return len(bc.blocks)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to delete the transactions of the blocks which are deeper than PruneDepth
+ * @description: The headers and Merkle roots are kept so the pruned range can still be verified with VerifyChain.
+ * @param: instance of blockchain
+ * @return: error if the block store could not be pruned
+ **/
+
+func (bc *Blockchain) Prune() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.pruneLocked()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to prune the blocks, it must be called with the write lock held
+ * @return: error if the block store could not be pruned
+ **/
+
+func (bc *Blockchain) pruneLocked() error {
+	below := len(bc.blocks) - bc.PruneDepth
+	if bc.PruneDepth <= 0 || below <= bc.prunedHeightLocked() {
+		return nil
+	}
+
+	if bc.Store != nil {
+		if err := bc.Store.Prune(below); err != nil {
+			return err
+		}
+	}
+	for height := 0; height < below; height++ {
+		if !bc.blocks[height].Pruned {
+			bc.blocks[height] = bc.blocks[height].Header()
+		}
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to verify a pruned block using its header only
+ * @description: The hash covers the header, so it is recalculated from the nonce, the previous hash and the Merkle root;
+ * @description: the block must link to the previous one and its hash must meet the difficulty of its height. The
+ * @description: transactions themselves are gone, the Merkle root of a pruned block cannot be checked against them.
+ * @param: instance of block, previous block (nil for the first block of the chain), height int of the block
+ * @return: bool
+ **/
+
+func VerifyHeader(currentBlock *Block, previousBlock *Block, height int) bool {
+	if previousBlock != nil && currentBlock.PreviousHash != previousBlock.CurrentHash {
+		return false
+	}
+	if len(currentBlock.MerkleRoot) == 0 || currentBlock.CurrentHash != currentBlock.CalculateHash() {
+		return false
+	}
+	return isValidHash(currentBlock.CurrentHash, difficultyAt(height))
+}
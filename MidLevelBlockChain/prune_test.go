@@ -0,0 +1,129 @@
+package MidLevelBlockchain
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that a pruned header is bound to its hash: a header whose nonce, Merkle root or hash
+ * @description: was changed fails the verification, even when its hash meets the difficulty
+ **/
+
+func TestVerifyHeaderRecalculatesHash(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for i := 0; i < 3; i++ {
+		if bc.ForceMineBlock([]string{fmt.Sprintf("transaction %d", i)}, bc.LatestHash()) == nil {
+			t.Fatalf("mining block %d failed", i)
+		}
+	}
+	previous, header := bc.BlockAt(1), bc.BlockAt(2).Header()
+	if !VerifyHeader(header, previous, 2) {
+		t.Fatal("the header of a valid block does not verify")
+	}
+
+	forgedRoot := *header
+	forgedRoot.MerkleRoot = strings.Repeat("ab", 32)
+	forgedHash := *header
+	forgedHash.CurrentHash = strings.Repeat("0", 64)
+	forgedNonce := *header
+	forgedNonce.Nonce++
+	for name, forged := range map[string]*Block{"Merkle root": &forgedRoot, "hash": &forgedHash, "nonce": &forgedNonce} {
+		if VerifyHeader(forged, previous, 2) {
+			t.Errorf("a header with a forged %s verifies", name)
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that the pruned blocks of a snapshot are only imported by a pruned node, at the
+ * @description: heights it would prune itself, so a node keeping whole blocks cannot be given bare headers
+ **/
+
+func TestImportPrunedSnapshot(t *testing.T) {
+	source := newTestBlockchain(t)
+	source.PruneDepth = 2
+	for i := 0; i < 6; i++ {
+		if source.ForceMineBlock([]string{fmt.Sprintf("transaction %d", i)}, source.LatestHash()) == nil {
+			t.Fatalf("mining block %d failed", i)
+		}
+	}
+	if err := source.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "pruned.jsonl")
+	if _, err := source.Export(path, "jsonl", 0, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, depth := range []int{0, 4} {
+		bc := newTestBlockchain(t)
+		bc.PruneDepth = depth
+		if _, err := bc.Import(path); !errors.Is(err, ErrPrunedSnapshot) {
+			t.Errorf("import by a node with prune depth %d returned %v, want %v", depth, err, ErrPrunedSnapshot)
+		}
+		if bc.Height() != 0 {
+			t.Errorf("refused import added blocks, height is %d", bc.Height())
+		}
+	}
+
+	bc := newTestBlockchain(t)
+	bc.PruneDepth = source.PruneDepth
+	if _, err := bc.Import(path); err != nil {
+		t.Fatalf("import by a node with the same prune depth failed: %v", err)
+	}
+	if bc.LatestHash() != source.LatestHash() {
+		t.Error("the imported chain does not end at the tip of the snapshot")
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that the block file stays usable after it was rewritten by the pruning: blocks are
+ * @description: appended to the new file and the store reopens with the pruned and the new blocks
+ **/
+
+func TestFileStoreRewrite(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenFileBlockStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := NewBlockchain(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.PruneDepth = 2
+	for i := 0; i < 5; i++ {
+		if bc.ForceMineBlock([]string{fmt.Sprintf("transaction %d", i)}, bc.LatestHash()) == nil {
+			t.Fatalf("mining block %d failed", i)
+		}
+		if i == 3 {
+			if err := bc.Prune(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tip := bc.LatestHash()
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = OpenFileBlockStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	blocks, err := store.Blocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 5 || blocks[4].CurrentHash != tip || !blocks[0].Pruned || blocks[4].Pruned {
+		t.Fatalf("reopened store holds %d block(s), want the pruned and the new blocks", len(blocks))
+	}
+}
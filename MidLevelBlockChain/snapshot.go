@@ -0,0 +1,307 @@
+package MidLevelBlockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const SnapshotFormatJSONLines = "jsonl" // One JSON encoded block per line, easy to feed to analysis tools
+const SnapshotFormatBinary = "binary"   // Compact length prefixed binary blocks
+const snapshotMagic = "MLBC"            // First bytes of a binary snapshot
+const manifestSuffix = ".manifest.json" // The manifest is written next to the snapshot file
+
+var (
+	ErrSnapshotChecksum = errors.New("snapshot checksum does not match the manifest")
+	ErrPrunedSnapshot   = errors.New("snapshot holds pruned blocks which this node keeps whole")
+)
+
+// SnapshotManifest describes an exported snapshot, it is written next to the snapshot file.
+type SnapshotManifest struct {
+	Format     string
+	FromHeight int // Height of the first exported block
+	ToHeight   int // Height of the last exported block
+	Blocks     int
+	TipHash    string
+	SHA256     string // Checksum of the snapshot file
+	CreatedAt  time.Time
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the path of the manifest of the snapshot file
+ * @param: path string of the snapshot file
+ * @return: path string of the manifest
+ **/
+
+func ManifestPath(path string) string {
+	return path + manifestSuffix
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to export the blocks between the heights (inclusive) to the snapshot file
+ * @description: A manifest with the checksum of the snapshot is written next to it.
+ * @param: path string of the snapshot, format string (jsonl or binary), from and to heights, to < 0 exports up to the tip
+ * @return: instance of manifest and error if any
+ **/
+
+func (bc *Blockchain) Export(path string, format string, from int, to int) (*SnapshotManifest, error) {
+	chain := bc.Snapshot()
+	if to < 0 || to >= len(chain) {
+		to = len(chain) - 1
+	}
+	if from < 0 || from > to {
+		return nil, fmt.Errorf("invalid height range %d-%d for a chain of %d block(s)", from, to, len(chain))
+	}
+	blocks := chain[from : to+1]
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	w := bufio.NewWriter(io.MultiWriter(file, hash))
+	switch format {
+	case SnapshotFormatJSONLines:
+		err = writeJSONLines(w, blocks)
+	case SnapshotFormatBinary:
+		err = writeBinarySnapshot(w, blocks)
+	default:
+		err = fmt.Errorf("unknown snapshot format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := file.Sync(); err != nil {
+		return nil, err
+	}
+
+	manifest := &SnapshotManifest{
+		Format:     format,
+		FromHeight: from,
+		ToHeight:   to,
+		Blocks:     len(blocks),
+		TipHash:    blocks[len(blocks)-1].CurrentHash,
+		SHA256:     fmt.Sprintf("%x", hash.Sum(nil)),
+		CreatedAt:  time.Now().UTC(),
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return manifest, WriteFileAtomic(ManifestPath(path), data)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to import the snapshot file into the blockchain
+ * @description: The checksum is checked against the manifest and every block is validated with the rules of VerifyChain.
+ * @description: Pruned blocks are only accepted by a pruned node, at the heights it would prune itself, and are checked
+ * @description: by their headers; as in a pruned chain, they have to come before the others.
+ * @description: The snapshot must extend the chain: the blocks it shares with the chain must be identical and the first
+ * @description: new block must link to the tip. Nothing is added unless the whole snapshot is valid.
+ * @param: path string of the snapshot
+ * @return: number of added blocks and error if any
+ **/
+
+func (bc *Blockchain) Import(path string) (int, error) {
+	manifest, blocks, err := ReadSnapshot(path)
+	if err != nil {
+		return 0, err
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if manifest.FromHeight > len(bc.blocks) {
+		return 0, fmt.Errorf("snapshot starts at height %d but the chain only has %d block(s)", manifest.FromHeight, len(bc.blocks))
+	}
+
+	var previousBlock *Block
+	if manifest.FromHeight > 0 {
+		previousBlock = bc.blocks[manifest.FromHeight-1]
+	}
+
+	prunable := manifest.FromHeight + len(blocks) - bc.PruneDepth // Heights below it are pruned once the snapshot is added
+	var newBlocks []*Block
+	for i, block := range blocks {
+		height := manifest.FromHeight + i
+		if height < len(bc.blocks) {
+			if bc.blocks[height].CurrentHash != block.CurrentHash {
+				return 0, fmt.Errorf("block %d of the snapshot conflicts with the chain", height)
+			}
+		} else {
+			if block.Pruned {
+				if bc.PruneDepth <= 0 || height >= prunable {
+					return 0, fmt.Errorf("block %d of the snapshot: %w", height, ErrPrunedSnapshot)
+				}
+				if previousBlock != nil && !previousBlock.Pruned {
+					return 0, fmt.Errorf("block %d of the snapshot is pruned but the block before it is not", height)
+				}
+				if !VerifyHeader(block, previousBlock, height) {
+					return 0, fmt.Errorf("header of block %d of the snapshot is invalid", height)
+				}
+			} else if !VerifyBlock(block, previousBlock) {
+				return 0, fmt.Errorf("block %d of the snapshot is invalid", height)
+			}
+			newBlocks = append(newBlocks, block)
+		}
+		previousBlock = block
+	}
+
+	for _, block := range newBlocks {
+		if err := bc.addBlockLocked(block); err != nil {
+			return 0, err
+		}
+	}
+	return len(newBlocks), nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read the snapshot file and its manifest, the checksum and block count are checked
+ * @param: path string of the snapshot
+ * @return: instance of manifest, blocks of the snapshot and error if any
+ **/
+
+func ReadSnapshot(path string) (*SnapshotManifest, []*Block, error) {
+	data, err := os.ReadFile(ManifestPath(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	var manifest SnapshotManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fmt.Sprintf("%x", sha256.Sum256(data)) != manifest.SHA256 {
+		return nil, nil, ErrSnapshotChecksum
+	}
+
+	var blocks []*Block
+	switch manifest.Format {
+	case SnapshotFormatJSONLines:
+		blocks, err = readJSONLines(data)
+	case SnapshotFormatBinary:
+		blocks, err = readBinarySnapshot(data)
+	default:
+		err = fmt.Errorf("unknown snapshot format %q", manifest.Format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(blocks) != manifest.Blocks || manifest.ToHeight-manifest.FromHeight+1 != len(blocks) {
+		return nil, nil, fmt.Errorf("snapshot holds %d block(s) but the manifest expects %d", len(blocks), manifest.Blocks)
+	}
+	if len(blocks) == 0 || blocks[len(blocks)-1].CurrentHash != manifest.TipHash {
+		return nil, nil, errors.New("snapshot tip does not match the manifest")
+	}
+	return &manifest, blocks, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: These functions are used to write and read the blocks in the JSON Lines format
+ **/
+
+func writeJSONLines(w io.Writer, blocks []*Block) error {
+	encoder := json.NewEncoder(w) // Encode writes a newline after every block
+	for _, block := range blocks {
+		if err := encoder.Encode(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readJSONLines(data []byte) ([]*Block, error) {
+	var blocks []*Block
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBlockRecordSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var block Block
+		if err := json.Unmarshal(scanner.Bytes(), &block); err != nil {
+			return nil, fmt.Errorf("block %d: %w", len(blocks), err)
+		}
+		blocks = append(blocks, &block)
+	}
+	return blocks, scanner.Err()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: These functions are used to write and read the blocks in the binary format
+ * @description: The snapshot starts with the magic bytes, then every block is prefixed by its length as a 4 byte big endian integer.
+ **/
+
+func writeBinarySnapshot(w io.Writer, blocks []*Block) error {
+	if _, err := io.WriteString(w, snapshotMagic); err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		encoded, err := block.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(encoded)))
+		if _, err := w.Write(length[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(encoded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readBinarySnapshot(data []byte) ([]*Block, error) {
+	if len(data) < len(snapshotMagic) || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, errors.New("not a binary snapshot")
+	}
+	r := bytes.NewReader(data[len(snapshotMagic):])
+
+	var blocks []*Block
+	for {
+		var length [4]byte
+		if _, err := io.ReadFull(r, length[:]); err == io.EOF {
+			return blocks, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("block %d: %w", len(blocks), ErrInvalidEncoding)
+		}
+
+		size := binary.BigEndian.Uint32(length[:])
+		if size > maxBlockRecordSize || int64(size) > int64(r.Len()) {
+			return nil, fmt.Errorf("block %d: %w", len(blocks), ErrInvalidEncoding)
+		}
+		encoded := make([]byte, size)
+		if _, err := io.ReadFull(r, encoded); err != nil {
+			return nil, fmt.Errorf("block %d: %w", len(blocks), ErrInvalidEncoding)
+		}
+
+		var block Block
+		if err := block.UnmarshalBinary(encoded); err != nil {
+			return nil, fmt.Errorf("block %d: %w", len(blocks), err)
+		}
+		blocks = append(blocks, &block)
+	}
+}
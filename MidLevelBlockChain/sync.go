@@ -0,0 +1,148 @@
+package MidLevelBlockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownParent    = errors.New("block does not extend the tip of the chain")
+	ErrInvalidBlock     = errors.New("block hash or Merkle root is invalid")
+	ErrInsufficientWork = errors.New("block hash does not meet the difficulty")
+	ErrKnownBlock       = errors.New("block is already in the chain")
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check that the block can be added on top of the chain
+ * @description: The block must link to the tip, have a valid hash and Merkle root and meet the difficulty of its height.
+ * @param: instance of block
+ * @return: error wrapping ErrUnknownParent, ErrInvalidBlock or ErrInsufficientWork if the block is refused
+ **/
+
+func (bc *Blockchain) ValidateNextBlock(block *Block) error {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.validateNextBlockLocked(block)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to validate the next block, it must be called with the lock held
+ * @param: instance of block
+ * @return: error if the block is refused
+ **/
+
+func (bc *Blockchain) validateNextBlockLocked(block *Block) error {
+	if block.PreviousHash != bc.latestHashLocked() {
+		return fmt.Errorf("%w: parent %s", ErrUnknownParent, limitHashDisplay(block.PreviousHash, 16))
+	}
+	var previous *Block
+	if len(bc.blocks) > 0 {
+		previous = bc.blocks[len(bc.blocks)-1]
+	}
+	if block.Pruned || !VerifyBlock(block, previous) {
+		return ErrInvalidBlock
+	}
+	if !isValidHash(block.CurrentHash, difficultyAt(len(bc.blocks))) {
+		return ErrInsufficientWork
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the height of the block with the given hash
+ * @param: hash string
+ * @return: height int, -1 if the block is not in the chain
+ **/
+
+func (bc *Blockchain) HeightOf(hash string) int {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.heightOfLocked(hash)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the height of the block with the given hash, it must be called with the lock held
+ * @param: hash string
+ * @return: height int, -1 if the block is not in the chain
+ **/
+
+func (bc *Blockchain) heightOfLocked(hash string) int {
+	for height := len(bc.blocks) - 1; height >= 0; height-- {
+		if bc.blocks[height].CurrentHash == hash {
+			return height
+		}
+	}
+	return -1
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the block with the given hash
+ * @param: hash string
+ * @return: instance of block, nil if the block is not in the chain
+ **/
+
+func (bc *Blockchain) BlockByHash(hash string) *Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if height := bc.heightOfLocked(hash); height >= 0 {
+		return bc.blocks[height]
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the block locator of the chain, it is sent to a peer to find the last common block
+ * @description: It has the hashes of the last 10 blocks, then goes back exponentially and always ends with the first block.
+ * @return: slice of hashes from the tip to the first block
+ **/
+
+func (bc *Blockchain) Locator() []string {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	var locator []string
+	step := 1
+	for height := len(bc.blocks) - 1; height >= 0; height -= step {
+		locator = append(locator, bc.blocks[height].CurrentHash)
+		if len(locator) >= 10 {
+			step *= 2
+		}
+		if height > 0 && height-step < 0 {
+			height = step // The next iteration adds the first block
+		}
+	}
+	return locator
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the blocks following the last block of the locator which is in the chain
+ * @param: locator slice of hashes, stopHash string of the last block wanted (empty for none), max int number of blocks
+ * @return: slice of blocks, from the first block of the chain if no hash of the locator is known
+ **/
+
+func (bc *Blockchain) BlocksAfter(locator []string, stopHash string, max int) []*Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	start := 0
+	for _, hash := range locator {
+		if height := bc.heightOfLocked(hash); height >= 0 {
+			start = height + 1
+			break
+		}
+	}
+
+	var blocks []*Block
+	for height := start; height < len(bc.blocks) && len(blocks) < max; height++ {
+		blocks = append(blocks, bc.blocks[height])
+		if bc.blocks[height].CurrentHash == stopHash {
+			break
+		}
+	}
+	return blocks
+}
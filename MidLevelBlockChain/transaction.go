@@ -0,0 +1,174 @@
+package MidLevelBlockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Transaction represents a single transaction waiting in the mempool to be mined.
+// Plain transactions only carry Data, transactions with a sender or inputs can be replaced by fee.
+// A transaction with a sender must be signed by it, and only the sender can replace its pending transactions.
+// Inputs have no owner, a transaction without a sender spending them can be replaced by any spender of the same inputs.
+type Transaction struct {
+	Sender    string   `json:",omitempty"` // Hex ed25519 public key of the account which signs the transaction
+	Nonce     int      `json:",omitempty"` // Sequence number of the sender, a replacement reuses it
+	Fee       int      `json:",omitempty"` // Fee paid to the miner
+	Inputs    []string `json:",omitempty"` // Outputs spent by the transaction, a replacement spends the same
+	Data      string
+	Signature string `json:",omitempty"` // Hex signature of the sender over the transaction without its signature
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the new transaction
+ * @param: data string of the transaction
+ * @return: instance of transaction
+ **/
+
+func NewTransaction(data string) *Transaction {
+	return &Transaction{Data: data}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create a transaction which cancels the pending one
+ * @description: It reuses the sender nonce and inputs so it conflicts with the original, the fee must be higher to replace it.
+ * @description: It has to be signed by the sender of the original before it is submitted.
+ * @param: instance of transaction to cancel, fee int of the cancellation
+ * @return: instance of transaction
+ **/
+
+func NewCancellation(tx *Transaction, fee int) *Transaction {
+	return &Transaction{
+		Sender: tx.Sender,
+		Nonce:  tx.Nonce,
+		Fee:    fee,
+		Inputs: tx.Inputs,
+		Data:   fmt.Sprintf("cancel %s", tx.Hash()),
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to sign the transaction, the sender is set to the public key of the signing key
+ * @param: ed25519 private key of the sender
+ **/
+
+func (tx *Transaction) Sign(key ed25519.PrivateKey) {
+	tx.Sender = hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	tx.Signature = hex.EncodeToString(ed25519.Sign(key, tx.signedBytes()))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the transaction carries a valid signature of its sender
+ * @return: bool
+ **/
+
+func (tx *Transaction) Signed() bool {
+	key, err := hex.DecodeString(tx.Sender)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	signature, err := hex.DecodeString(tx.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(key), tx.signedBytes(), signature)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the bytes covered by the signature, the encoded transaction without it
+ * @return: byte slice
+ **/
+
+func (tx *Transaction) signedBytes() []byte {
+	unsigned := *tx
+	unsigned.Signature = ""
+	return []byte(unsigned.Encode())
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the transaction can be replaced by fee
+ * @param: instance of transaction
+ * @return: bool
+ **/
+
+func (tx *Transaction) Replaceable() bool {
+	return tx.Sender != "" || len(tx.Inputs) > 0
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if two transactions conflict, i.e. they use the same sender nonce or spend the same input
+ * @param: instance of transaction and the other transaction
+ * @return: bool
+ **/
+
+func (tx *Transaction) ConflictsWith(other *Transaction) bool {
+	if tx.Sender != "" && tx.Sender == other.Sender && tx.Nonce == other.Nonce {
+		return true
+	}
+	for _, input := range tx.Inputs {
+		for _, otherInput := range other.Inputs {
+			if input == otherInput {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the transaction into the string form which is stored in the block
+ * @description: Plain transactions are stored as their data so older blocks stay readable, the others are stored as JSON.
+ * @param: instance of transaction
+ * @return: encoded transaction string
+ **/
+
+func (tx *Transaction) Encode() string {
+	if !tx.Replaceable() && tx.Fee == 0 {
+		return tx.Data
+	}
+	encoded, err := json.Marshal(tx)
+	if err != nil {
+		return tx.Data
+	}
+	return string(encoded)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to parse the transaction from the string form which is stored in the block
+ * @param: raw transaction string
+ * @return: instance of transaction
+ **/
+
+func ParseTransaction(raw string) *Transaction {
+	if strings.HasPrefix(raw, "{") {
+		var tx Transaction
+		if err := json.Unmarshal([]byte(raw), &tx); err == nil && tx.Encode() == raw {
+			return &tx
+		}
+	}
+	return &Transaction{Data: raw}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to calculate the hash of the transaction, it is used as the transaction id
+ * @param: instance of transaction
+ * @return: hash of the transaction
+ **/
+
+func (tx *Transaction) Hash() string {
+	hash := sha256.Sum256([]byte(tx.Encode()))
+	return fmt.Sprintf("%x", hash)
+}
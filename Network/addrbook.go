@@ -0,0 +1,265 @@
+package network
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+const maxAddressBookSize = 1000     // Addresses kept in the address book
+const maxRetryBackoff = time.Minute // Longest wait before an unreachable address is dialed again
+
+// AddressEntry is a node address known to the address book.
+type AddressEntry struct {
+	Address     string
+	Source      string    // seed, manual, peer or gossip
+	LastSeen    time.Time // Last successful handshake
+	Attempts    int       // Failed connection attempts since the last successful one
+	NextAttempt time.Time // The address is not dialed again before this time
+}
+
+// AddressBook keeps the addresses of the nodes which can be connected to, it is persisted to a JSON file.
+type AddressBook struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*AddressEntry
+	dirty   bool
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the address book which is persisted to the given file
+ * @param: path string of the file, the address book is only kept in memory if it is empty
+ * @return: instance of address book
+ **/
+
+func NewAddressBook(path string) *AddressBook {
+	return &AddressBook{path: path, entries: make(map[string]*AddressEntry)}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to load the address book from its file, a missing file is not an error
+ * @return: error if any
+ **/
+
+func (ab *AddressBook) Load() error {
+	if ab.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(ab.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries []*AddressEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	for _, entry := range entries {
+		if validAddress(entry.Address) {
+			ab.entries[entry.Address] = entry
+		}
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to save the address book to its file if it changed
+ * @return: error if any
+ **/
+
+func (ab *AddressBook) Save() error {
+	ab.mu.Lock()
+	if ab.path == "" || !ab.dirty {
+		ab.mu.Unlock()
+		return nil
+	}
+	entries := ab.sortedEntries()
+	ab.dirty = false
+	ab.mu.Unlock()
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ab.path), 0o755); err != nil {
+		return err
+	}
+	return MidLevelBlockchain.WriteFileAtomic(ab.path, data)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add the address to the address book
+ * @param: address string, source string of the address
+ * @return: true if the address is new
+ **/
+
+func (ab *AddressBook) Add(addr string, source string) bool {
+	if !validAddress(addr) {
+		return false
+	}
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	if entry, ok := ab.entries[addr]; ok {
+		// Addresses given by the user are retried right away
+		if source == "seed" || source == "manual" {
+			entry.Attempts = 0
+			entry.NextAttempt = time.Time{}
+		}
+		return false
+	}
+	if len(ab.entries) >= maxAddressBookSize {
+		return false
+	}
+	ab.entries[addr] = &AddressEntry{Address: addr, Source: source}
+	ab.dirty = true
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to remove the address from the address book
+ * @param: address string
+ **/
+
+func (ab *AddressBook) Remove(addr string) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	if _, ok := ab.entries[addr]; ok {
+		delete(ab.entries, addr)
+		ab.dirty = true
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to record a successful handshake with the address
+ * @param: address string
+ **/
+
+func (ab *AddressBook) MarkGood(addr string) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	if entry, ok := ab.entries[addr]; ok {
+		entry.LastSeen = time.Now()
+		entry.Attempts = 0
+		entry.NextAttempt = time.Time{}
+		ab.dirty = true
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to record a failed connection attempt, the next attempt is delayed exponentially
+ * @param: address string
+ **/
+
+func (ab *AddressBook) MarkFailed(addr string) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	if entry, ok := ab.entries[addr]; ok {
+		entry.Attempts++
+		backoff := time.Second << uint(min(entry.Attempts, 10))
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+		entry.NextAttempt = time.Now().Add(backoff)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the addresses which can be dialed now, in random order
+ * @return: slice of addresses
+ **/
+
+func (ab *AddressBook) Dialable() []string {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	now := time.Now()
+	var addrs []string
+	for addr, entry := range ab.entries {
+		if !now.Before(entry.NextAttempt) {
+			addrs = append(addrs, addr)
+		}
+	}
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	return addrs
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get a random sample of the addresses, it is sent to the peers asking for addresses
+ * @param: max int number of addresses
+ * @return: slice of addresses
+ **/
+
+func (ab *AddressBook) Sample(max int) []string {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	addrs := make([]string, 0, len(ab.entries))
+	for addr := range ab.entries {
+		addrs = append(addrs, addr)
+	}
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	if len(addrs) > max {
+		addrs = addrs[:max]
+	}
+	return addrs
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get a copy of the entries of the address book sorted by address
+ * @return: slice of address entries
+ **/
+
+func (ab *AddressBook) Entries() []AddressEntry {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	var entries []AddressEntry
+	for _, entry := range ab.sortedEntries() {
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+func (ab *AddressBook) sortedEntries() []*AddressEntry {
+	entries := make([]*AddressEntry, 0, len(ab.entries))
+	for _, entry := range ab.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Address < entries[j].Address })
+	return entries
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check that the address has the host:port form
+ * @param: address string
+ * @return: bool
+ **/
+
+func validAddress(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	return err == nil && host != "" && port != ""
+}
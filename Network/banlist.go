@@ -0,0 +1,172 @@
+package network
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+const DefaultBanDuration = 24 * time.Hour // How long a misbehaving peer stays banned
+
+// BanEntry is a banned node ID, IP address or peer address.
+type BanEntry struct {
+	Key    string // Node ID (hex) or IP address the peer was seen connecting from, or an address banned by the user
+	Reason string
+	Until  time.Time
+}
+
+// BanList keeps the banned peers until their ban expires, it is persisted to a JSON file.
+type BanList struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*BanEntry
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the ban list which is persisted to the given file
+ * @param: path string of the file, the ban list is only kept in memory if it is empty
+ * @return: instance of ban list
+ **/
+
+func NewBanList(path string) *BanList {
+	return &BanList{path: path, entries: make(map[string]*BanEntry)}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to load the ban list from its file, a missing file is not an error
+ * @return: error if any
+ **/
+
+func (bl *BanList) Load() error {
+	if bl.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(bl.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries []*BanEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	for _, entry := range entries {
+		if entry.Key != "" && time.Now().Before(entry.Until) {
+			bl.entries[entry.Key] = entry
+		}
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to save the ban list to its file
+ * @return: error if any
+ **/
+
+func (bl *BanList) save() error {
+	if bl.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(bl.sortedEntries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(bl.path), 0o755); err != nil {
+		return err
+	}
+	return MidLevelBlockchain.WriteFileAtomic(bl.path, data)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to ban the peer address or node ID, the ban list is saved right away
+ * @param: key string (address or node ID), reason string, duration of the ban
+ * @return: error if the ban list could not be saved
+ **/
+
+func (bl *BanList) Ban(key string, reason string, duration time.Duration) error {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	bl.entries[key] = &BanEntry{Key: key, Reason: reason, Until: time.Now().Add(duration)}
+	return bl.save()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to lift the ban of the peer address or node ID
+ * @param: key string (address or node ID)
+ * @return: false if the key was not banned, error if the ban list could not be saved
+ **/
+
+func (bl *BanList) Unban(key string) (bool, error) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	if _, ok := bl.entries[key]; !ok {
+		return false, nil
+	}
+	delete(bl.entries, key)
+	return true, bl.save()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if any of the keys is banned, expired bans are removed
+ * @param: keys (addresses or node IDs)
+ * @return: bool
+ **/
+
+func (bl *BanList) IsBanned(keys ...string) bool {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	for _, key := range keys {
+		entry, ok := bl.entries[key]
+		if !ok {
+			continue
+		}
+		if time.Now().Before(entry.Until) {
+			return true
+		}
+		delete(bl.entries, key)
+	}
+	return false
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get a copy of the bans which did not expire, sorted by key
+ * @return: slice of ban entries
+ **/
+
+func (bl *BanList) Entries() []BanEntry {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	var entries []BanEntry
+	for _, entry := range bl.sortedEntries() {
+		if time.Now().Before(entry.Until) {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries
+}
+
+func (bl *BanList) sortedEntries() []*BanEntry {
+	entries := make([]*BanEntry, 0, len(bl.entries))
+	for _, entry := range bl.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
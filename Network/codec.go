@@ -0,0 +1,465 @@
+package network
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+var DefaultCodecs = []string{"binary", "json"} // Codecs offered in the handshake, most preferred first
+
+var (
+	ErrUnknownCodec    = errors.New("unknown codec")
+	ErrInvalidEncoding = MidLevelBlockchain.ErrInvalidEncoding
+	ErrUnsupportedType = errors.New("type cannot be encoded by the binary codec")
+)
+
+// Codec encodes the messages and their payloads on the wire. The codec of a connection is negotiated in the handshake.
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes the values as JSON, it is easy to read when debugging.
+type JSONCodec struct{}
+
+// BinaryCodec encodes the values in a compact and deterministic binary format:
+// integers are varints, strings, byte slices and slices are prefixed by their length, byte arrays are written as is,
+// struct fields are written in order, a pointer is prefixed by a presence byte and map entries are sorted by key.
+// Types implementing encoding.BinaryMarshaler, like the blocks, are written with it. Fields tagged json:"-" are skipped.
+type BinaryCodec struct{}
+
+var codecs = map[string]Codec{
+	"json":   JSONCodec{},
+	"binary": BinaryCodec{},
+}
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to find the codec by its name
+ * @param: name of the codec
+ * @return: instance of codec and false if there is no such codec
+ **/
+
+func CodecByName(name string) (Codec, bool) {
+	codec, ok := codecs[name]
+	return codec, ok
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to parse the comma-separated list of codecs, most preferred first
+ * @param: list string
+ * @return: slice of codec names and error if a codec is unknown
+ **/
+
+func ParseCodecs(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := CodecByName(name); !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: empty list", ErrUnknownCodec)
+	}
+	return names, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to choose the codec of a connection, it is the first codec offered by the side which
+ * @description: opened the connection which the other side supports, so both sides choose the same one.
+ * @description: JSON is used when the sides share no codec, a peer which offers none only speaks JSON.
+ * @param: codecs offered by the side which opened the connection, codecs offered by the other side
+ * @return: instance of codec
+ **/
+
+func negotiateCodec(dialer []string, listener []string) Codec {
+	if len(dialer) == 0 || len(listener) == 0 {
+		return JSONCodec{}
+	}
+	for _, name := range dialer {
+		codec, ok := CodecByName(name)
+		if !ok {
+			continue
+		}
+		for _, other := range listener {
+			if name == other {
+				return codec
+			}
+		}
+	}
+	return JSONCodec{}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the name of the codec
+ * @return: name string
+ **/
+
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the value as JSON
+ * @param: value
+ * @return: byte slice and error if any
+ **/
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to decode the JSON into the value
+ * @param: byte slice, pointer to the value
+ * @return: error if any
+ **/
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the name of the codec
+ * @return: name string
+ **/
+
+func (BinaryCodec) Name() string {
+	return "binary"
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the value in the binary format
+ * @param: value
+ * @return: byte slice and error if the value holds a type which cannot be encoded
+ **/
+
+func (BinaryCodec) Marshal(v interface{}) ([]byte, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem() // Encoded like the value it points to, as it is decoded through a pointer
+	}
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to decode the binary format into the value, all of the data must be used
+ * @param: byte slice, pointer to the value
+ * @return: error if the data is not a valid encoding of the value
+ **/
+
+func (BinaryCodec) Unmarshal(data []byte, v interface{}) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("%w: decoding needs a non-nil pointer", ErrUnsupportedType)
+	}
+	r := bytes.NewReader(data)
+	if err := decodeValue(r, ptr.Elem()); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidEncoding, r.Len())
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to write the value in the binary format
+ * @param: buffer and the value
+ * @return: error if the value holds a type which cannot be encoded
+ **/
+
+func encodeValue(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		return fmt.Errorf("%w: nil", ErrUnsupportedType)
+	}
+	if v.Kind() != reflect.Pointer && reflect.PointerTo(v.Type()).Implements(binaryMarshalerType) {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		data, err := ptr.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		MidLevelBlockchain.WriteBytes(buf, data)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		MidLevelBlockchain.WriteVarint(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		MidLevelBlockchain.WriteUvarint(buf, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		var tmp [8]byte
+		binary.BigEndian.PutUint64(tmp[:], math.Float64bits(v.Float()))
+		buf.Write(tmp[:])
+	case reflect.String:
+		MidLevelBlockchain.WriteBytes(buf, []byte(v.String()))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			MidLevelBlockchain.WriteBytes(buf, v.Bytes())
+			return nil
+		}
+		MidLevelBlockchain.WriteUvarint(buf, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeValue(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			for i := 0; i < v.Len(); i++ {
+				buf.WriteByte(byte(v.Index(i).Uint()))
+			}
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeValue(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for _, i := range wireFields(v.Type()) {
+			if err := encodeValue(buf, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			buf.WriteByte(0)
+			return nil
+		}
+		buf.WriteByte(1)
+		return encodeValue(buf, v.Elem())
+	case reflect.Map:
+		return encodeMap(buf, v)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to write the map with its entries sorted by their encoded key, so the encoding is deterministic
+ * @param: buffer and the map
+ * @return: error if the map holds a type which cannot be encoded
+ **/
+
+func encodeMap(buf *bytes.Buffer, v reflect.Value) error {
+	type entry struct {
+		key   []byte
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		var key bytes.Buffer
+		if err := encodeValue(&key, iter.Key()); err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: key.Bytes(), value: iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+
+	MidLevelBlockchain.WriteUvarint(buf, uint64(len(entries)))
+	for _, e := range entries {
+		buf.Write(e.key)
+		if err := encodeValue(buf, e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read the value of the binary format
+ * @param: reader of the encoded data, settable value
+ * @return: error if the data is not a valid encoding of the value
+ **/
+
+func decodeValue(r *bytes.Reader, v reflect.Value) error {
+	if v.Kind() != reflect.Pointer && reflect.PointerTo(v.Type()).Implements(binaryUnmarshalerType) {
+		data, err := MidLevelBlockchain.ReadBytes(r)
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := r.ReadByte()
+		if err != nil || b > 1 {
+			return ErrInvalidEncoding
+		}
+		v.SetBool(b == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := binary.ReadVarint(r)
+		if err != nil || v.OverflowInt(n) {
+			return ErrInvalidEncoding
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := binary.ReadUvarint(r)
+		if err != nil || v.OverflowUint(n) {
+			return ErrInvalidEncoding
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var tmp [8]byte
+		if _, err := io.ReadFull(r, tmp[:]); err != nil {
+			return ErrInvalidEncoding
+		}
+		v.SetFloat(math.Float64frombits(binary.BigEndian.Uint64(tmp[:])))
+	case reflect.String:
+		data, err := MidLevelBlockchain.ReadBytes(r)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(data))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := MidLevelBlockchain.ReadBytes(r)
+			if err != nil {
+				return err
+			}
+			if len(data) == 0 {
+				data = nil
+			}
+			v.SetBytes(data)
+			return nil
+		}
+		count, err := MidLevelBlockchain.ReadLength(r)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		slice := reflect.MakeSlice(v.Type(), count, count)
+		for i := 0; i < count; i++ {
+			if err := decodeValue(r, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			if _, err := io.ReadFull(r, data); err != nil {
+				return ErrInvalidEncoding
+			}
+			reflect.Copy(v, reflect.ValueOf(data))
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := decodeValue(r, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for _, i := range wireFields(v.Type()) {
+			if err := decodeValue(r, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		present, err := r.ReadByte()
+		if err != nil || present > 1 {
+			return ErrInvalidEncoding
+		}
+		if present == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeValue(r, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Map:
+		count, err := MidLevelBlockchain.ReadLength(r)
+		if err != nil {
+			return err
+		}
+		m := reflect.MakeMapWithSize(v.Type(), count)
+		for i := 0; i < count; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(r, key); err != nil {
+				return err
+			}
+			if err := decodeValue(r, value); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the fields of the struct which are on the wire,
+ * @description: the exported fields which are not tagged json:"-"
+ * @param: type of the struct
+ * @return: slice of field indexes in order
+ **/
+
+func wireFields(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && field.Tag.Get("json") != "-" {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
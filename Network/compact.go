@@ -0,0 +1,271 @@
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"log"
+	"math/rand"
+	"time"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+const shortIDSize = 6        // Bytes of a short transaction ID
+const maxPartialBlocks = 100 // Compact blocks kept while their missing transactions are downloaded
+const maxBlockTxs = 1000000  // Transactions of a compact block which is accepted
+
+// CompactBlockPayload announces a new block with its header and the short IDs of its transactions.
+// The receiver rebuilds the block from its mempool and only requests the transactions it does not have.
+type CompactBlockPayload struct {
+	Header   *MidLevelBlockchain.Block // The block without its transactions
+	Salt     uint64                    // Mixed into the short IDs so they differ from block to block
+	ShortIDs []byte                    // shortIDSize bytes per transaction, in block order
+}
+
+// GetBlockTxnPayload requests the transactions of a compact block which the node could not find in its mempool.
+type GetBlockTxnPayload struct {
+	BlockHash string
+	Indexes   []int
+}
+
+// BlockTxnPayload is the reply to GetBlockTxn, the transactions are in the order of the requested indexes.
+type BlockTxnPayload struct {
+	BlockHash    string
+	Transactions []string
+}
+
+// partialBlock is a compact block waiting for its missing transactions.
+type partialBlock struct {
+	header   *MidLevelBlockchain.Block
+	txs      []string
+	missing  []int
+	from     string
+	received time.Time
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to compute the short ID of the transaction in the block
+ * @param: hash of the block, salt of the compact block, raw transaction string
+ * @return: short ID of shortIDSize bytes
+ **/
+
+func shortTxID(blockHash string, salt uint64, tx string) [shortIDSize]byte {
+	var saltBytes [8]byte
+	binary.BigEndian.PutUint64(saltBytes[:], salt)
+	h := sha256.New()
+	h.Write([]byte(blockHash))
+	h.Write(saltBytes[:])
+	h.Write([]byte(tx))
+
+	var id [shortIDSize]byte
+	copy(id[:], h.Sum(nil))
+	return id
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the compact block announcing the block
+ * @param: instance of block
+ * @return: instance of compact block payload
+ **/
+
+func newCompactBlock(block *MidLevelBlockchain.Block) CompactBlockPayload {
+	payload := CompactBlockPayload{Header: block.Header(), Salt: rand.Uint64()}
+	payload.Header.Pruned = false
+	payload.ShortIDs = make([]byte, 0, len(block.Transactions)*shortIDSize)
+	for _, tx := range block.Transactions {
+		id := shortTxID(block.CurrentHash, payload.Salt, tx)
+		payload.ShortIDs = append(payload.ShortIDs, id[:]...)
+	}
+	return payload
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to announce the new block to every peer except the one it came from
+ * @description: Peers which accept compact blocks get one, the others get an inventory and download the whole block.
+ * @param: instance of block, address of the peer which sent the block (empty if it was mined locally)
+ * @return: instance of broadcast tracking the delivery to each peer
+ **/
+
+func (n *Node) relayBlock(block *MidLevelBlockchain.Block, from string) *Broadcast {
+	item := InvItem{Type: InvBlock, Hash: block.CurrentHash}
+	broadcast := &Broadcast{}
+	if from == "" {
+		n.trackReceipts(item.Hash, broadcast) // Before sending, a peer may reply at once
+	}
+	var compactMsg *Message
+	for _, addr := range n.broadcastAddresses() {
+		if addr == from {
+			continue
+		}
+		if services, ok := n.PeerServices(addr); !ok || !services.CompactBlocks {
+			broadcast.send(n, addr, &Message{Type: "Inv", Payload: InvPayload{Items: []InvItem{item}}, From: n.Address})
+			continue
+		}
+		if compactMsg == nil {
+			compactMsg = &Message{Type: "CmpctBlock", Payload: newCompactBlock(block), From: n.Address}
+		}
+		broadcast.send(n, addr, compactMsg)
+	}
+	return broadcast
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to rebuild the compact block from the mempool
+ * @description: The missing transactions are requested from the peer; a block which does not extend the tip is
+ * @description: downloaded whole instead, so a node which is behind starts a sync.
+ * @param: address of the peer, instance of compact block payload
+ **/
+
+func (n *Node) handleCompactBlock(from string, payload CompactBlockPayload) {
+	header := payload.Header
+	if header == nil || len(payload.ShortIDs) == 0 || len(payload.ShortIDs)%shortIDSize != 0 || len(payload.ShortIDs)/shortIDSize > maxBlockTxs {
+		n.Misbehaving(from, penaltyProtocolViolation, "invalid compact block")
+		return
+	}
+	item := InvItem{Type: InvBlock, Hash: header.CurrentHash}
+	n.confirmReceipt(from, item.Hash)
+	if n.seen.Contains(item) || n.haveItem(item) {
+		return
+	}
+
+	n.mu.Lock()
+	if partial, ok := n.compact[header.CurrentHash]; ok && time.Since(partial.received) < getDataTimeout {
+		n.mu.Unlock()
+		return // Already being rebuilt from the announcement of another peer
+	}
+	n.mu.Unlock()
+
+	if header.PreviousHash != n.Blockchain.LatestHash() {
+		n.requestFullBlock(from, item)
+		return
+	}
+
+	pending := make(map[[shortIDSize]byte]string)
+	if n.Mempool != nil {
+		for _, tx := range n.Mempool.PendingTransactions() {
+			raw := tx.Encode()
+			pending[shortTxID(header.CurrentHash, payload.Salt, raw)] = raw
+		}
+	}
+
+	count := len(payload.ShortIDs) / shortIDSize
+	partial := &partialBlock{header: header, txs: make([]string, count), from: from, received: time.Now()}
+	for i := 0; i < count; i++ {
+		var id [shortIDSize]byte
+		copy(id[:], payload.ShortIDs[i*shortIDSize:])
+		if tx, ok := pending[id]; ok {
+			partial.txs[i] = tx
+		} else {
+			partial.missing = append(partial.missing, i)
+		}
+	}
+	if len(partial.missing) == 0 {
+		n.completeCompactBlock(partial)
+		return
+	}
+
+	n.mu.Lock()
+	if len(n.compact) >= maxPartialBlocks {
+		for hash, stale := range n.compact {
+			if time.Since(stale.received) >= getDataTimeout || len(n.compact) >= maxPartialBlocks {
+				delete(n.compact, hash)
+			}
+		}
+	}
+	n.compact[header.CurrentHash] = partial
+	n.mu.Unlock()
+
+	request := GetBlockTxnPayload{BlockHash: header.CurrentHash, Indexes: partial.missing}
+	n.sendMessage(from, &Message{Type: "GetBlockTxn", Payload: request, From: n.Address})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the requested transactions of the block
+ * @param: address of the peer, instance of get block transactions payload
+ **/
+
+func (n *Node) handleGetBlockTxn(from string, payload GetBlockTxnPayload) {
+	n.confirmReceipt(from, payload.BlockHash)
+	block := n.Blockchain.BlockByHash(payload.BlockHash)
+	if block == nil || block.Pruned {
+		n.sendInventory(from, "NotFound", []InvItem{{Type: InvBlock, Hash: payload.BlockHash}})
+		return
+	}
+
+	reply := BlockTxnPayload{BlockHash: payload.BlockHash}
+	for _, index := range payload.Indexes {
+		if index < 0 || index >= len(block.Transactions) {
+			n.Misbehaving(from, penaltyProtocolViolation, "block transaction index out of range")
+			return
+		}
+		reply.Transactions = append(reply.Transactions, block.Transactions[index])
+	}
+	n.sendMessage(from, &Message{Type: "BlockTxn", Payload: reply, From: n.Address})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to complete the compact block with the transactions received from the peer
+ * @param: address of the peer, instance of block transactions payload
+ **/
+
+func (n *Node) handleBlockTxn(from string, payload BlockTxnPayload) {
+	n.mu.Lock()
+	partial, ok := n.compact[payload.BlockHash]
+	if ok && partial.from == from {
+		delete(n.compact, payload.BlockHash)
+	}
+	n.mu.Unlock()
+	if !ok || partial.from != from {
+		return
+	}
+
+	if len(payload.Transactions) != len(partial.missing) {
+		n.Misbehaving(from, penaltyProtocolViolation, "wrong number of block transactions")
+		n.requestFullBlock(from, InvItem{Type: InvBlock, Hash: payload.BlockHash})
+		return
+	}
+	for i, index := range partial.missing {
+		partial.txs[index] = payload.Transactions[i]
+	}
+	partial.missing = nil
+	n.completeCompactBlock(partial)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to process the rebuilt block
+ * @description: If its hash or Merkle root does not match, a transaction of the mempool had the same short ID as
+ * @description: a transaction of the block, and the whole block is downloaded instead.
+ * @param: instance of partial block
+ **/
+
+func (n *Node) completeCompactBlock(partial *partialBlock) {
+	block := *partial.header
+	block.Transactions = partial.txs
+	block.Pruned = false
+	if !MidLevelBlockchain.VerifyBlock(&block, nil) {
+		n.requestFullBlock(partial.from, InvItem{Type: InvBlock, Hash: block.CurrentHash})
+		return
+	}
+	if err := n.processBlock(&block, partial.from); err != nil {
+		log.Println("Block rejected:", err)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to download the whole block from the peer
+ * @param: address of the peer, instance of inventory item of the block
+ **/
+
+func (n *Node) requestFullBlock(from string, item InvItem) {
+	if n.requestOnce(item) {
+		n.sendInventory(from, "GetData", []InvItem{item})
+	}
+}
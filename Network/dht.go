@@ -0,0 +1,430 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const dhtAlpha = 3                        // Parallel FindNode requests of a lookup
+const dhtRequestTimeout = 5 * time.Second // How long a DHT request waits for its response
+const dhtRefreshInterval = time.Minute    // How often a random lookup refreshes the routing table
+const dhtBootstrapRetry = 5 * time.Second // Wait before the bootstrap is retried
+const maxNeighbors = bucketSize           // Contacts sent in a single Neighbors message
+const dhtAddressSource = "dht"            // Source of the addresses learned from the DHT in the address book
+
+var (
+	ErrRequestTimeout   = errors.New("request timed out")
+	ErrNoBootstrapPeers = errors.New("no bootstrap peer could be reached")
+	ErrUnexpectedReply  = errors.New("unexpected reply to the request")
+	ErrNoIdentity       = errors.New("peer did not announce an identity key")
+)
+
+// FindNodePayload asks a node for the contacts it knows closest to the target.
+type FindNodePayload struct {
+	Target NodeID
+}
+
+// NeighborsPayload is the reply to FindNode.
+type NeighborsPayload struct {
+	Contacts []Contact
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to set the identity key of the node, the routing table is reset for the new node ID
+ * @description: and the TLS certificate of the node is created for the key.
+ * @description: It has to be called before the node is started.
+ * @param: ed25519 private key
+ **/
+
+func (n *Node) SetIdentity(key ed25519.PrivateKey) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.Identity = key
+	n.dht = NewRoutingTable(NodeIDFromKey(key.Public().(ed25519.PublicKey)))
+	certificate, err := newNodeCertificate(key)
+	if err != nil {
+		log.Println("Error creating the node certificate:", err)
+		return
+	}
+	n.certificate = certificate
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the node ID, it is derived from the identity key
+ * @return: node ID
+ **/
+
+func (n *Node) ID() NodeID {
+	return NodeIDFromKey(n.Identity.Public().(ed25519.PublicKey))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the routing table of the DHT
+ * @return: instance of routing table
+ **/
+
+func (n *Node) RoutingTable() *RoutingTable {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.dht
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the DHT contact of the connected peer
+ * @param: address of the peer
+ * @return: instance of contact and false if the peer is not connected or has no identity
+ **/
+
+func (n *Node) peerContact(addr string) (Contact, bool) {
+	n.mu.Lock()
+	peer, ok := n.peerAt(addr)
+	n.mu.Unlock()
+	if !ok || len(peer.Version.PublicKey) != ed25519.PublicKeySize {
+		return Contact{}, false
+	}
+	return Contact{ID: NodeIDFromKey(peer.Version.PublicKey), Address: addr}, true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to record in the routing table that the contact was seen
+ * @description: If its bucket is full the least recently seen contact is pinged and replaced if it does not answer.
+ * @param: instance of contact
+ **/
+
+func (n *Node) dhtSeen(contact Contact) {
+	rt := n.RoutingTable()
+	stale, full := rt.Update(contact)
+	if !full {
+		return
+	}
+	go func() {
+		if err := n.Ping(stale.Address); err != nil {
+			rt.Replace(stale, contact)
+			return
+		}
+		rt.Update(stale)
+	}()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send a request to the node and wait for its response
+ * @param: address of the node, instance of message
+ * @return: response message and error if the node could not be reached or did not answer in time
+ **/
+
+func (n *Node) request(addr string, msg *Message) (*Message, error) {
+	peer, err := n.connectPeer(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	id := atomic.AddUint64(&n.nextRequestID, 1)
+	reply := make(chan *Message, 1)
+	n.mu.Lock()
+	n.requests[id] = reply
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		delete(n.requests, id)
+		n.mu.Unlock()
+	}()
+
+	msg.RequestID = id
+	if !peer.Send(msg) {
+		return nil, fmt.Errorf("%s request to %s could not be queued", msg.Type, addr)
+	}
+
+	timer := time.NewTimer(dhtRequestTimeout)
+	defer timer.Stop()
+	select {
+	case resp := <-reply:
+		return resp, nil
+	case <-peer.done:
+		return nil, fmt.Errorf("%s request to %s: peer disconnected", msg.Type, addr)
+	case <-timer.C:
+		return nil, fmt.Errorf("%s request to %s: %w", msg.Type, addr, ErrRequestTimeout)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to hand the response over to the request waiting for it
+ * @param: instance of message
+ **/
+
+func (n *Node) deliverResponse(msg *Message) {
+	n.mu.Lock()
+	reply, ok := n.requests[msg.RequestID]
+	n.mu.Unlock()
+	if !ok {
+		return // The request timed out
+	}
+	select {
+	case reply <- msg:
+	default:
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to reply to the request of a peer
+ * @param: request message, reply message
+ **/
+
+func (n *Node) reply(req *Message, resp *Message) {
+	resp.RequestID = req.RequestID
+	resp.From = n.Address
+	n.sendMessage(req.From, resp)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check that the node is alive with a DHT ping
+ * @param: address of the node
+ * @return: error if the node did not answer
+ **/
+
+func (n *Node) Ping(addr string) error {
+	resp, err := n.request(addr, &Message{Type: "DHTPing", From: n.Address})
+	if err != nil {
+		return err
+	}
+	if resp.Type != "DHTPong" {
+		return fmt.Errorf("%w: %s", ErrUnexpectedReply, resp.Type)
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to ask the contact for the nodes it knows closest to the target
+ * @param: instance of contact, target node ID
+ * @return: slice of contacts and error if any
+ **/
+
+func (n *Node) findNodeRPC(contact Contact, target NodeID) ([]Contact, error) {
+	resp, err := n.request(contact.Address, &Message{Type: "FindNode", Payload: FindNodePayload{Target: target}, From: n.Address})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Type != "Neighbors" {
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedReply, resp.Type)
+	}
+	var payload NeighborsPayload
+	if err := resp.DecodePayload(&payload); err != nil {
+		return nil, err
+	}
+	if len(payload.Contacts) > maxNeighbors {
+		payload.Contacts = payload.Contacts[:maxNeighbors]
+	}
+	return payload.Contacts, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to find the nodes closest to the target in the whole network
+ * @param: target node ID
+ * @return: slice of contacts sorted by distance to the target
+ **/
+
+func (n *Node) FindNode(target NodeID) []Contact {
+	contacts, _ := n.lookup(target)
+	return contacts
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is the iterative Kademlia lookup
+ * @description: The closest contacts which were not queried yet are asked for their neighbors, dhtAlpha at a time,
+ * @description: until the bucketSize closest contacts known have all answered. Contacts which do not answer are dropped.
+ * @param: target node ID
+ * @return: slice of contacts sorted by distance to the target and the number of rounds of requests
+ **/
+
+func (n *Node) lookup(target NodeID) ([]Contact, int) {
+	rt := n.RoutingTable()
+	self := n.ID()
+	shortlist := rt.Closest(target, bucketSize)
+	seen := map[NodeID]bool{self: true}
+	for _, contact := range shortlist {
+		seen[contact.ID] = true
+	}
+	queried := make(map[NodeID]bool)
+	rounds := 0
+
+	for {
+		var batch []Contact
+		for _, contact := range shortlist {
+			if !queried[contact.ID] {
+				batch = append(batch, contact)
+				if len(batch) == dhtAlpha {
+					break
+				}
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+		rounds++
+
+		results := make([][]Contact, len(batch))
+		errs := make([]error, len(batch))
+		var wg sync.WaitGroup
+		for i, contact := range batch {
+			queried[contact.ID] = true
+			wg.Add(1)
+			go func(i int, contact Contact) {
+				defer wg.Done()
+				results[i], errs[i] = n.findNodeRPC(contact, target)
+			}(i, contact)
+		}
+		wg.Wait()
+
+		failed := make(map[NodeID]bool)
+		for i, contact := range batch {
+			if errs[i] != nil {
+				failed[contact.ID] = true
+				rt.Remove(contact.ID)
+				n.AddressBook.MarkFailed(contact.Address)
+				continue
+			}
+			for _, found := range results[i] {
+				if seen[found.ID] || !validAddress(found.Address) {
+					continue
+				}
+				seen[found.ID] = true
+				shortlist = append(shortlist, found)
+				n.AddressBook.Add(found.Address, dhtAddressSource)
+			}
+		}
+
+		kept := shortlist[:0]
+		for _, contact := range shortlist {
+			if !failed[contact.ID] {
+				kept = append(kept, contact)
+			}
+		}
+		shortlist = kept
+		sortByDistance(shortlist, target)
+		if len(shortlist) > bucketSize {
+			shortlist = shortlist[:bucketSize]
+		}
+	}
+	return shortlist, rounds
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to join the DHT through the bootstrap nodes
+ * @description: The node looks up its own ID, which fills the buckets close to it, then refreshes the farther buckets.
+ * @param: addresses of the bootstrap nodes
+ * @return: error if no bootstrap node could be reached
+ **/
+
+func (n *Node) BootstrapDHT(seeds []string) error {
+	reached := 0
+	for _, seed := range seeds {
+		if seed == n.Address {
+			continue
+		}
+		if _, err := n.connectPeer(seed); err != nil {
+			log.Printf("Bootstrap node %s unreachable: %v\n", seed, err)
+			continue
+		}
+		if _, ok := n.peerContact(seed); !ok {
+			log.Printf("Bootstrap node %s: %v\n", seed, ErrNoIdentity)
+			continue
+		}
+		reached++
+	}
+	if reached == 0 && n.RoutingTable().Len() == 0 {
+		return ErrNoBootstrapPeers
+	}
+
+	n.lookup(n.ID())
+	rt := n.RoutingTable()
+	for index := rt.deepestBucket() - 1; index >= 0; index-- {
+		n.lookup(rt.randomIDInBucket(index))
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is the loop of the DHT, it joins the DHT then refreshes the routing table periodically
+ * @description: It returns when the node stops.
+ * @param: addresses of the bootstrap nodes
+ **/
+
+func (n *Node) runDHT(seeds []string) {
+	ctx := n.context()
+	for {
+		err := n.BootstrapDHT(seeds)
+		if err == nil {
+			break
+		}
+		if len(seeds) == 0 {
+			return // First node of the network, the others bootstrap from it
+		}
+		log.Println("Error joining the DHT:", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dhtBootstrapRetry):
+		}
+	}
+	log.Printf("Joined the DHT as %s with %d contact(s)\n", n.ID().Short(), n.RoutingTable().Len())
+
+	ticker := time.NewTicker(dhtRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.lookup(randomNodeID())
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to handle the DHT messages
+ * @param: instance of message
+ **/
+
+func (n *Node) handleDHTMessage(msg *Message) {
+	if contact, ok := n.peerContact(msg.From); ok {
+		n.dhtSeen(contact)
+	}
+
+	switch msg.Type {
+	case "DHTPing":
+		n.reply(msg, &Message{Type: "DHTPong"})
+	case "FindNode":
+		var payload FindNodePayload
+		if !n.decodePayload(msg, &payload) {
+			return
+		}
+		var contacts []Contact
+		for _, contact := range n.RoutingTable().Closest(payload.Target, maxNeighbors+1) {
+			if contact.Address != msg.From && len(contacts) < maxNeighbors {
+				contacts = append(contacts, contact)
+			}
+		}
+		n.reply(msg, &Message{Type: "Neighbors", Payload: NeighborsPayload{Contacts: contacts}})
+	case "DHTPong", "Neighbors":
+		n.deliverResponse(msg)
+	}
+}
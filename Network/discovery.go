@@ -0,0 +1,210 @@
+package network
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+const DefaultMaxOutbound = 8                 // Outbound connections the node tries to keep open
+const discoveryInterval = 2 * time.Second    // How often missing outbound connections are opened
+const addrRequestInterval = 30 * time.Second // How often a random peer is asked for more addresses
+const maxAddrPerMessage = 250                // Addresses sent in a single Addr message
+
+var ErrInvalidAddress = errors.New("address must have the host:port form")
+
+// AddrPayload carries the addresses known by a node, it is sent in reply to GetAddr.
+type AddrPayload struct {
+	Addresses []string
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to start the peer discovery
+ * @description: The seeds are added to the address book, then missing outbound connections are opened in the
+ * @description: background and the peers are asked for the addresses they know (addr/getaddr gossip).
+ * @description: The node also joins the DHT through the seeds, the addresses found by its lookups feed the address book.
+ * @description: The discovery runs until the node stops.
+ * @param: seeds are the bootstrap addresses
+ * @return: error if the node is not running
+ **/
+
+func (n *Node) StartDiscovery(seeds []string) error {
+	if !n.Running() {
+		return ErrNodeStopped
+	}
+	var bootstrap []string
+	for _, seed := range seeds {
+		if seed != n.Address {
+			n.AddressBook.Add(seed, "seed")
+			bootstrap = append(bootstrap, seed)
+		}
+	}
+	n.spawn(n.runDiscovery)
+	n.spawn(func() { n.runDHT(bootstrap) })
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is the loop of the peer discovery, it returns when the node stops
+ **/
+
+func (n *Node) runDiscovery() {
+	ticker := time.NewTicker(discoveryInterval)
+	defer ticker.Stop()
+	lastAddrRequest := time.Now()
+	ctx := n.context()
+
+	for {
+		n.fillOutboundSlots()
+		n.checkSync()
+
+		if time.Since(lastAddrRequest) >= addrRequestInterval {
+			lastAddrRequest = time.Now()
+			if peers := n.Peers(); len(peers) > 0 {
+				n.requestAddresses(peers[rand.Intn(len(peers))])
+			}
+		}
+		if err := n.AddressBook.Save(); err != nil {
+			log.Println("Error saving the address book:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to dial addresses of the address book until MaxOutbound peers are connected
+ **/
+
+func (n *Node) fillOutboundSlots() {
+	outbound := 0
+	connected := make(map[string]bool)
+	for _, peer := range n.Peers() {
+		connected[peer.Address()] = true
+		if !peer.Inbound {
+			outbound++
+		}
+	}
+
+	for _, addr := range n.AddressBook.Dialable() {
+		if outbound >= n.MaxOutbound {
+			return
+		}
+		if connected[addr] || addr == n.Address || n.isRemoved(addr) || n.isBannedAddress(addr) {
+			continue
+		}
+		outbound++
+		go func(addr string) {
+			if _, err := n.connectPeer(addr); err != nil {
+				n.AddressBook.MarkFailed(addr)
+			}
+		}(addr)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to ask the peer for the addresses it knows
+ * @param: instance of peer
+ **/
+
+func (n *Node) requestAddresses(peer *Peer) {
+	peer.Send(&Message{Type: "GetAddr", From: n.Address})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to reply to GetAddr with a sample of the address book
+ * @param: address of the peer which asked
+ **/
+
+func (n *Node) sendAddresses(addr string) {
+	var addrs []string
+	for _, known := range n.AddressBook.Sample(maxAddrPerMessage + 1) {
+		if known != addr && len(addrs) < maxAddrPerMessage {
+			addrs = append(addrs, known)
+		}
+	}
+
+	n.sendMessage(addr, &Message{Type: "Addr", Payload: AddrPayload{Addresses: addrs}, From: n.Address})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add the addresses received from a peer to the address book
+ * @param: instance of addr payload
+ **/
+
+func (n *Node) receiveAddresses(payload AddrPayload) {
+	added := 0
+	for i, addr := range payload.Addresses {
+		if i >= maxAddrPerMessage {
+			break
+		}
+		if addr != n.Address && !n.isRemoved(addr) && n.AddressBook.Add(addr, "gossip") {
+			added++
+		}
+	}
+	if added > 0 {
+		log.Printf("Learned %d new peer address(es)\n", added)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add a peer at runtime and connect to it
+ * @param: address of the peer
+ * @return: error if the address is invalid or the connection failed
+ **/
+
+func (n *Node) AddPeer(addr string) error {
+	if !validAddress(addr) {
+		return ErrInvalidAddress
+	}
+	n.mu.Lock()
+	delete(n.removed, addr)
+	n.mu.Unlock()
+
+	n.AddressBook.Add(addr, "manual")
+	_, err := n.connectPeer(addr)
+	return err
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to remove a peer at runtime, it is disconnected and not dialed again
+ * @param: address of the peer
+ **/
+
+func (n *Node) RemovePeer(addr string) {
+	n.mu.Lock()
+	n.removed[addr] = true
+	peer, _ := n.peerAt(addr)
+	n.mu.Unlock()
+
+	n.AddressBook.Remove(addr)
+	if peer != nil {
+		peer.Close()
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the peer was removed by the user
+ * @param: address of the peer
+ * @return: bool
+ **/
+
+func (n *Node) isRemoved(addr string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.removed[addr]
+}
@@ -0,0 +1,210 @@
+package network
+
+import (
+	"container/list"
+	"log"
+	"sync"
+	"time"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+const seenCacheSize = 20000             // Blocks and transactions remembered by the seen-cache
+const getDataTimeout = 30 * time.Second // An item requested from a peer which did not arrive by then is requested again
+
+// seenCache is a bounded set of the inventory items the node already processed, the oldest items are forgotten first.
+type seenCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // Keys from the most to the least recently added
+	items map[string]*list.Element
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the seen-cache
+ * @param: size int, maximum number of items
+ * @return: instance of seen cache
+ **/
+
+func newSeenCache(size int) *seenCache {
+	return &seenCache{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add the item to the seen-cache
+ * @param: instance of inventory item
+ * @return: true if the item was not seen before
+ **/
+
+func (c *seenCache) Add(item InvItem) bool {
+	key := item.Type + ":" + item.Hash
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.order.MoveToFront(element)
+		return false
+	}
+	c.items[key] = c.order.PushFront(key)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(string))
+	}
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the item is in the seen-cache
+ * @param: instance of inventory item
+ * @return: bool
+ **/
+
+func (c *seenCache) Contains(item InvItem) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.items[item.Type+":"+item.Hash]
+	return ok
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to announce the item to every peer except the one it came from
+ * @param: instance of inventory item, address of the peer which sent the item (empty if it is local)
+ * @return: instance of broadcast tracking the delivery to each peer
+ **/
+
+func (n *Node) relayInventory(item InvItem, from string) *Broadcast {
+	broadcast := &Broadcast{}
+	for _, addr := range n.broadcastAddresses() {
+		if addr != from {
+			broadcast.send(n, addr, &Message{Type: "Inv", Payload: InvPayload{Items: []InvItem{item}}, From: n.Address})
+		}
+	}
+	return broadcast
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the node already has the item
+ * @param: instance of inventory item
+ * @return: bool
+ **/
+
+func (n *Node) haveItem(item InvItem) bool {
+	switch item.Type {
+	case InvBlock:
+		return n.Blockchain.HeightOf(item.Hash) >= 0
+	case InvTx:
+		return n.Mempool != nil && n.Mempool.FindTransaction(item.Hash) != nil
+	}
+	return false
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to reserve the download of the item, so it is requested from a single peer at a time
+ * @param: instance of inventory item
+ * @return: false if the item was already requested and did not time out yet
+ **/
+
+func (n *Node) requestOnce(item InvItem) bool {
+	key := item.Type + ":" + item.Hash
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	if requested, ok := n.inFlight[key]; ok && now.Sub(requested) < getDataTimeout {
+		return false
+	}
+	if len(n.inFlight) >= maxInvItems {
+		for key, requested := range n.inFlight {
+			if now.Sub(requested) >= getDataTimeout {
+				delete(n.inFlight, key)
+			}
+		}
+	}
+	n.inFlight[key] = now
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to mark the item as received, it is not downloaded or relayed again
+ * @param: instance of inventory item
+ * @return: true if the item was not seen before
+ **/
+
+func (n *Node) markSeen(item InvItem) bool {
+	n.mu.Lock()
+	delete(n.inFlight, item.Type+":"+item.Hash)
+	n.mu.Unlock()
+	return n.seen.Add(item)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to request the announced items which the node does not have yet
+ * @param: address of the peer, slice of announced items
+ **/
+
+func (n *Node) fetchAnnounced(addr string, items []InvItem) {
+	var wanted []InvItem
+	for _, item := range items {
+		if item.Type != InvBlock && item.Type != InvTx {
+			continue
+		}
+		if n.seen.Contains(item) || n.haveItem(item) || !n.requestOnce(item) {
+			continue
+		}
+		wanted = append(wanted, item)
+	}
+	if len(wanted) > 0 {
+		n.sendInventory(addr, "GetData", wanted)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the requested pending transaction to the peer
+ * @param: address of the peer, hash of the transaction
+ * @return: false if the transaction is not in the mempool
+ **/
+
+func (n *Node) sendTransaction(addr string, hash string) bool {
+	if n.Mempool == nil {
+		return false
+	}
+	tx := n.Mempool.FindTransaction(hash)
+	if tx == nil || tx.Hash() != hash {
+		return false
+	}
+	n.sendMessage(addr, &Message{Type: "Tx", Payload: tx.Encode(), From: n.Address})
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add the transaction received from the peer to the mempool and announce it to the other peers
+ * @param: raw transaction string, address of the peer
+ **/
+
+func (n *Node) processTransaction(transaction string, from string) {
+	tx := MidLevelBlockchain.ParseTransaction(transaction)
+	if !n.markSeen(InvItem{Type: InvTx, Hash: tx.Hash()}) || n.Mempool == nil {
+		return
+	}
+	replaced, err := n.Mempool.AddTransaction(tx)
+	if err != nil {
+		log.Println("Transaction rejected:", err)
+		return
+	}
+	log.Println("New transaction added to the mempool")
+	if len(replaced) > 0 {
+		log.Printf("Transaction replaced %d pending transaction(s).\n", len(replaced))
+	}
+	n.relayInventory(InvItem{Type: InvTx, Hash: tx.Hash()}, from)
+}
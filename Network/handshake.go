@@ -0,0 +1,164 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const ProtocolVersion = 7                      // Version of the wire protocol spoken by this node
+const MinProtocolVersion = 4                   // Oldest version of the peers which is still accepted, version 4 put the message code in the frame header
+const DefaultChainID = "midlevel-testnet"      // Peers on another chain are refused
+const UserAgent = "/MidLevelBlockchain:0.1.0/" // Sent to the peers for diagnostics
+const codecsVersion = 5                        // First protocol version which negotiates the codec, older peers only speak JSON
+const streamsVersion = 7                       // First protocol version which cuts the frames into the chunks of streams
+const handshakeTimeout = 10 * time.Second
+
+var (
+	ErrChainMismatch       = errors.New("peer is on a different chain")
+	ErrIncompatibleVersion = errors.New("peer protocol version is not supported")
+	ErrSelfConnection      = errors.New("connected to ourselves")
+	ErrUnexpectedMessage   = errors.New("unexpected message during the handshake")
+	ErrAddressMismatch     = errors.New("peer announced another address than the one it was dialed at")
+	ErrAddressClaimed      = errors.New("address is claimed by another connected node")
+)
+
+// VersionInfo is exchanged by both sides when a connection is opened, before any other message.
+type VersionInfo struct {
+	ProtocolVersion int
+	ChainID         string
+	BestHeight      int
+	UserAgent       string
+	Address         string // Listening address of the node, only proven when the node is dialed at it
+	Services        NodeServices
+	PublicKey       []byte   `json:",omitempty"` // Identity key of the node, the node ID which identifies the node is derived from it
+	Codecs          []string `json:",omitempty"` // Codecs the node speaks after the handshake, most preferred first
+	FirstBlock      string   `json:",omitempty"` // Hash of the first block of the chain, empty while the chain has none
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the version which the node announces to its peers
+ * @return: instance of version info
+ **/
+
+func (n *Node) localVersion() VersionInfo {
+	return VersionInfo{
+		ProtocolVersion: n.protocol,
+		ChainID:         n.ChainID,
+		BestHeight:      n.Blockchain.Height(),
+		UserAgent:       UserAgent,
+		Address:         n.Address,
+		Services:        n.LocalServices(),
+		PublicKey:       n.Identity.Public().(ed25519.PublicKey),
+		Codecs:          n.Codecs,
+		FirstBlock:      n.firstBlockHash(),
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the hash of the first block of the chain of the node
+ * @return: hash string, empty if the chain has no block
+ **/
+
+func (n *Node) firstBlockHash() string {
+	if first := n.Blockchain.BlockAt(0); first != nil {
+		return first.CurrentHash
+	}
+	return ""
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the version of the peer is compatible with the node
+ * @description: A peer whose chain starts with another first block can never sync with the node, it is refused as well.
+ * @param: version of the peer
+ * @return: error if the peer must be refused
+ **/
+
+func (n *Node) checkVersion(version VersionInfo) error {
+	if version.ChainID != n.ChainID {
+		return fmt.Errorf("%w: %q", ErrChainMismatch, version.ChainID)
+	}
+	if first := n.firstBlockHash(); first != "" && version.FirstBlock != "" && version.FirstBlock != first {
+		return fmt.Errorf("%w: first block %s", ErrChainMismatch, version.FirstBlock)
+	}
+	if version.ProtocolVersion < MinProtocolVersion {
+		return fmt.Errorf("%w: %d", ErrIncompatibleVersion, version.ProtocolVersion)
+	}
+	if version.Address == n.Address || bytes.Equal(version.PublicKey, n.Identity.Public().(ed25519.PublicKey)) {
+		return ErrSelfConnection
+	}
+	if len(version.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: invalid identity key", ErrUnexpectedMessage)
+	}
+	if version.Address == "" {
+		return fmt.Errorf("%w: version without an address", ErrUnexpectedMessage)
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to run the version/verack handshake on a new connection
+ * @description: Both sides send their version, check the version of the other side and acknowledge it with a verack.
+ * @param: connection, inbound bool (the peer connected to us)
+ * @return: version of the peer and error if the handshake failed or the peer was refused
+ **/
+
+func (n *Node) handshake(conn net.Conn, inbound bool) (VersionInfo, error) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	// The handshake is always in JSON, the codec of the connection is chosen from the versions
+	versionMsg := &Message{Type: "Version", Payload: n.localVersion(), From: n.Address}
+	verackMsg := &Message{Type: "Verack", From: n.Address}
+
+	// The node which opened the connection speaks first
+	if !inbound {
+		if err := n.Messages.WriteFrame(conn, versionMsg, JSONCodec{}); err != nil {
+			return VersionInfo{}, err
+		}
+	}
+
+	msg, err := n.Messages.ReadFrame(conn, JSONCodec{})
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	if msg.Type != "Version" {
+		return VersionInfo{}, fmt.Errorf("%w: %s", ErrUnexpectedMessage, msg.Type)
+	}
+	var version VersionInfo
+	if err := msg.DecodePayload(&version); err != nil {
+		return VersionInfo{}, err
+	}
+
+	// The version is answered before it is checked, so a refused dialer sees why and does not retry
+	if inbound {
+		if err := n.Messages.WriteFrame(conn, versionMsg, JSONCodec{}); err != nil {
+			return VersionInfo{}, err
+		}
+	}
+	if err := n.checkVersion(version); err != nil {
+		return VersionInfo{}, err
+	}
+	if err := n.checkConnKey(conn, version); err != nil {
+		return VersionInfo{}, err
+	}
+	if err := n.Messages.WriteFrame(conn, verackMsg, JSONCodec{}); err != nil {
+		return VersionInfo{}, err
+	}
+
+	msg, err = n.Messages.ReadFrame(conn, JSONCodec{})
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	if msg.Type != "Verack" {
+		return VersionInfo{}, fmt.Errorf("%w: %s", ErrUnexpectedMessage, msg.Type)
+	}
+	return version, nil
+}
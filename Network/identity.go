@@ -0,0 +1,175 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const idBits = 256 // Length of a node ID in bits
+
+// NodeID identifies a node in the DHT, it is the SHA-256 hash of the public key of the node.
+type NodeID [sha256.Size]byte
+
+var ErrInvalidIdentity = errors.New("identity file does not contain an ed25519 key")
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to derive the node ID from the public key of the node
+ * @param: ed25519 public key
+ * @return: node ID
+ **/
+
+func NodeIDFromKey(pub ed25519.PublicKey) NodeID {
+	return NodeID(sha256.Sum256(pub))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the hex representation of the node ID
+ * @return: string
+ **/
+
+func (id NodeID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the short hex representation of the node ID, used in the logs
+ * @return: string
+ **/
+
+func (id NodeID) Short() string {
+	return hex.EncodeToString(id[:4])
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the node ID as hex in JSON
+ * @return: byte slice and error if any
+ **/
+
+func (id NodeID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to decode the node ID from hex in JSON
+ * @param: byte slice
+ * @return: error if any
+ **/
+
+func (id *NodeID) UnmarshalText(text []byte) error {
+	decoded, err := hex.DecodeString(string(text))
+	if err != nil || len(decoded) != len(id) {
+		return fmt.Errorf("invalid node ID %q", text)
+	}
+	copy(id[:], decoded)
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to compute the XOR distance between two node IDs
+ * @param: node ID
+ * @return: distance as a node ID, it is compared byte by byte
+ **/
+
+func (id NodeID) Distance(other NodeID) NodeID {
+	var d NodeID
+	for i := range id {
+		d[i] = id[i] ^ other[i]
+	}
+	return d
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the length of the prefix which two node IDs have in common,
+ * @description: it is the index of the k-bucket of the other node
+ * @param: node ID
+ * @return: number of leading bits in common, idBits if the IDs are equal
+ **/
+
+func (id NodeID) CommonPrefixLen(other NodeID) int {
+	for i := range id {
+		if x := id[i] ^ other[i]; x != 0 {
+			n := 0
+			for x&0x80 == 0 {
+				x <<= 1
+				n++
+			}
+			return i*8 + n
+		}
+	}
+	return idBits
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the node ID is closer to the target than the other one
+ * @param: other node ID, target node ID
+ * @return: bool
+ **/
+
+func (id NodeID) Closer(other NodeID, target NodeID) bool {
+	a, b := id.Distance(target), other.Distance(target)
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to generate a new random identity key
+ * @return: ed25519 private key
+ **/
+
+func GenerateIdentity() ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return key
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to load the identity key of the node, a new key is created and saved if the file does not exist
+ * @param: path string of the key file
+ * @return: ed25519 private key and error if any
+ **/
+
+func LoadOrCreateIdentity(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidIdentity, path)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key := GenerateIdentity()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key.Seed())+"\n"), 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,160 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
+)
+
+const stopTimeout = 10 * time.Second // How long Stop waits for the peers to write their queued messages
+
+var (
+	ErrNodeRunning = errors.New("node is already running")
+	ErrNodeStopped = errors.New("node is not running")
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to start the node, it listens on its address and accepts the peers in the background
+ * @description: The node runs until Stop is called or the context is cancelled. It can be started again once stopped.
+ * @param: context of the node
+ * @return: error if the node is already running or its address could not be listened on
+ **/
+
+func (n *Node) Start(ctx context.Context) error {
+	n.lifeMu.Lock()
+	defer n.lifeMu.Unlock()
+	if n.running {
+		return ErrNodeRunning
+	}
+	ln, err := n.Transport.Listen(n.Address)
+	if err != nil {
+		return err
+	}
+
+	n.ctx, n.cancel = context.WithCancel(ctx)
+	n.listener = ln
+	n.running = true
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.serve(ln)
+	}()
+	go func(ctx context.Context) {
+		<-ctx.Done()
+		n.stop(ctx)
+	}(n.ctx)
+	log.Printf("Node listening on %s\n", n.Address)
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to stop the node, it is safe to call more than once
+ * @description: The listener is closed and the background loops stop. The peers write the messages which are still
+ * @description: queued before their connection is closed; after stopTimeout the remaining peers are closed at once.
+ * @return: error if the listener could not be closed
+ **/
+
+func (n *Node) Stop() error {
+	return n.stop(nil)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to stop the node, or only the run of the given context if it is not nil
+ * @description: so a cancelled context cannot stop a later run of the node.
+ * @param: context of the run, nil for the current run
+ * @return: error if the listener could not be closed
+ **/
+
+func (n *Node) stop(ctx context.Context) error {
+	n.lifeMu.Lock()
+	if !n.running || (ctx != nil && ctx != n.ctx) {
+		n.lifeMu.Unlock()
+		return nil
+	}
+	n.running = false
+	n.cancel()
+	err := n.listener.Close()
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+	n.lifeMu.Unlock()
+
+	n.StopBlockProducer()
+	peers := n.Peers()
+	for _, peer := range peers {
+		peer.Drain()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(stopTimeout):
+		log.Printf("Peers did not drain within %v, closing them\n", stopTimeout)
+		for _, peer := range peers {
+			peer.Close()
+		}
+		<-stopped
+	}
+	log.Printf("Node %s stopped\n", n.Address)
+	return err
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the node is running
+ * @return: bool
+ **/
+
+func (n *Node) Running() bool {
+	n.lifeMu.Lock()
+	defer n.lifeMu.Unlock()
+	return n.running
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the context of the running node, it is cancelled when the node stops
+ * @return: context, a cancelled one if the node is not running
+ **/
+
+func (n *Node) context() context.Context {
+	n.lifeMu.Lock()
+	defer n.lifeMu.Unlock()
+	if !n.running {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+	return n.ctx
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to run the function in the background while the node is running, Stop waits for it
+ * @param: function
+ * @return: false if the node is not running, the function is not run then
+ **/
+
+func (n *Node) spawn(f func()) bool {
+	n.lifeMu.Lock()
+	defer n.lifeMu.Unlock()
+	if !n.running {
+		return false
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		f()
+	}()
+	return true
+}
@@ -0,0 +1,246 @@
+package network
+
+import (
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const DefaultPingInterval = 30 * time.Second // Time between two pings of a peer
+const DefaultPongTimeout = 20 * time.Second  // A peer which does not answer a ping for this long is disconnected
+const rttSmoothing = 8                       // Weight of the previous average in the smoothed round-trip time, as in TCP
+const reconnectAttempts = 3                  // Attempts to reconnect to an unresponsive outbound peer
+const reconnectDelay = 2 * time.Second       // Wait before the first attempt to reconnect, doubled after each attempt
+
+// PingPayload is the payload of the Ping message and of the Pong answering it, which echoes its nonce.
+type PingPayload struct {
+	Nonce uint64
+}
+
+// peerLiveness holds the ping state and the latency measured for a peer, it is guarded by the liveMu of the peer.
+type peerLiveness struct {
+	nonce     uint64    // Nonce of the outstanding ping, zero if none
+	pingSent  time.Time // When the outstanding ping was queued
+	lastSeen  time.Time // Last frame received from the peer
+	rtt       time.Duration
+	avgRTT    time.Duration // Smoothed over the pongs received
+	minRTT    time.Duration
+	pings     int
+	pongs     int
+	connected time.Time
+}
+
+// PeerMetrics is the liveness and latency of a connected peer.
+type PeerMetrics struct {
+	Address   string
+	Inbound   bool
+	Connected time.Time     // When the handshake completed
+	LastSeen  time.Time     // Last frame received from the peer
+	RTT       time.Duration // Round-trip time of the last pong, zero before the first one
+	AvgRTT    time.Duration // Smoothed round-trip time
+	MinRTT    time.Duration
+	Pings     int // Pings sent to the peer
+	Pongs     int // Pongs received in time from the peer
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is the ping loop of the peer, it pings the peer every PingInterval of the node
+ * @description: A peer which does not answer within PongTimeout is disconnected, and reconnected if we dialed it.
+ * @description: Peers on a protocol version without pings are not checked.
+ **/
+
+func (p *Peer) pingLoop() {
+	interval, timeout := p.node.PingInterval, p.node.PongTimeout
+	if interval <= 0 || !p.Supports("Ping") {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		nonce := rand.Uint64() | 1 // Zero means no ping is outstanding
+		p.liveMu.Lock()
+		p.live.nonce = nonce
+		p.live.pingSent = time.Now()
+		p.live.pings++
+		p.liveMu.Unlock()
+		if !p.Send(&Message{Type: "Ping", Payload: PingPayload{Nonce: nonce}, From: p.node.Address}) {
+			continue
+		}
+
+		timer := time.NewTimer(timeout)
+		select {
+		case <-p.done:
+			timer.Stop()
+			return
+		case <-p.pong:
+			timer.Stop()
+		case <-timer.C:
+			log.Printf("Peer %s did not answer a ping within %v, disconnecting\n", p.Address(), timeout)
+			p.Close()
+			p.node.reconnect(p)
+			return
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to record that a frame was received from the peer
+ **/
+
+func (p *Peer) touch() {
+	p.liveMu.Lock()
+	p.live.lastSeen = time.Now()
+	p.liveMu.Unlock()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to record the pong of the peer and measure the round-trip time of its ping
+ * @param: nonce echoed by the peer
+ * @return: false if the nonce is not the one of the outstanding ping
+ **/
+
+func (p *Peer) receivePong(nonce uint64) bool {
+	p.liveMu.Lock()
+	if nonce == 0 || nonce != p.live.nonce {
+		p.liveMu.Unlock()
+		return false
+	}
+	rtt := time.Since(p.live.pingSent)
+	p.live.nonce = 0
+	p.live.rtt = rtt
+	if p.live.pongs == 0 {
+		p.live.avgRTT, p.live.minRTT = rtt, rtt
+	} else {
+		p.live.avgRTT += (rtt - p.live.avgRTT) / rttSmoothing
+		p.live.minRTT = min(p.live.minRTT, rtt)
+	}
+	p.live.pongs++
+	p.liveMu.Unlock()
+
+	select {
+	case p.pong <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the liveness and latency of the peer
+ * @return: instance of peer metrics
+ **/
+
+func (p *Peer) Metrics() PeerMetrics {
+	p.liveMu.Lock()
+	defer p.liveMu.Unlock()
+	return PeerMetrics{
+		Address:   p.Address(),
+		Inbound:   p.Inbound,
+		Connected: p.live.connected,
+		LastSeen:  p.live.lastSeen,
+		RTT:       p.live.rtt,
+		AvgRTT:    p.live.avgRTT,
+		MinRTT:    p.live.minRTT,
+		Pings:     p.live.pings,
+		Pongs:     p.live.pongs,
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the liveness and latency of every connected peer
+ * @return: slice of peer metrics sorted by address
+ **/
+
+func (n *Node) PeerMetrics() []PeerMetrics {
+	var metrics []PeerMetrics
+	for _, peer := range n.Peers() {
+		metrics = append(metrics, peer.Metrics())
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Address < metrics[j].Address })
+	return metrics
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to answer the ping of a peer with a pong echoing its nonce
+ * @description: The pong is queued on the connection the ping came from, it is not retried.
+ * @param: address of the peer, payload of the ping
+ **/
+
+func (n *Node) handlePing(addr string, ping PingPayload) {
+	n.mu.Lock()
+	peer, ok := n.peerAt(addr)
+	n.mu.Unlock()
+	if ok {
+		peer.Send(&Message{Type: "Pong", Payload: ping, From: n.Address})
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to handle the pong of a peer, a pong which answers no ping is ignored
+ * @description: since it may come late, after the ping was given up.
+ * @param: address of the peer, payload of the pong
+ **/
+
+func (n *Node) handlePong(addr string, pong PingPayload) {
+	n.mu.Lock()
+	peer, ok := n.peerAt(addr)
+	n.mu.Unlock()
+	if ok {
+		peer.receivePong(pong.Nonce)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to reconnect to a peer which was disconnected because it stopped answering
+ * @description: Only the peers we dialed are reconnected, the inbound ones reconnect to us themselves. The attempts
+ * @description: back off exponentially and stop once the peer is connected again, removed, banned or the node stops.
+ * @param: instance of the disconnected peer
+ **/
+
+func (n *Node) reconnect(peer *Peer) {
+	addr := peer.Address()
+	if peer.Inbound || n.isRemoved(addr) {
+		return
+	}
+	n.spawn(func() {
+		ctx := n.context()
+		wait := reconnectDelay
+		for attempt := 1; attempt <= reconnectAttempts; attempt++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			wait *= 2
+
+			n.mu.Lock()
+			_, connected := n.peerAt(addr)
+			n.mu.Unlock()
+			if connected || n.isRemoved(addr) || n.isBannedAddress(addr) {
+				return
+			}
+			if _, err := n.connectPeer(addr); err != nil {
+				log.Printf("Reconnecting to peer %s failed (attempt %d of %d): %v\n", addr, attempt, reconnectAttempts, err)
+				n.AddressBook.MarkFailed(addr)
+				continue
+			}
+			log.Printf("Reconnected to peer %s\n", addr)
+			return
+		}
+	})
+}
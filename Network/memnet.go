@@ -0,0 +1,519 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const memoryRetransmitTimeout = 200 * time.Millisecond // Extra delay of a lost write, the smallest retransmission timeout of TCP
+
+var (
+	ErrConnectionRefused = errors.New("connection refused")
+	ErrConnectionReset   = errors.New("connection reset")
+	ErrPartitioned       = errors.New("address is in another partition")
+	ErrAddressInUse      = errors.New("address already in use")
+)
+
+// MemoryNetwork connects the nodes of one process without sockets, for simulations and tests.
+// Like a TCP connection, a memory connection is a reliable byte stream: the writes arrive in order, after the latency.
+// A lost write arrives a retransmission timeout later and holds back the writes after it, and a write may reset its
+// connection, which both sides then see as an error. A write across partitions resets its connection as well.
+// The faults are drawn from a random source seeded by NewMemoryNetwork, so a run can be repeated. The fault settings
+// must be set before the nodes are started, partitions can be changed at any time.
+type MemoryNetwork struct {
+	Latency   time.Duration // One-way delay of every write
+	Jitter    time.Duration // Random extra delay of a write, up to this, the writes still arrive in order
+	LossRate  float64       // Probability that a write is lost and sent again after memoryRetransmitTimeout
+	ResetRate float64       // Probability that a write resets its connection
+
+	mu        sync.Mutex
+	seed      int64 // Seed of rng, the simulations on the network draw their own choices from it as well
+	rng       *rand.Rand
+	listeners map[string]*memoryListener
+	groups    map[string]int // Partition of each address, addresses which are not listed are in partition 0
+}
+
+// memoryAddr is the address of a memory connection, it is the listening address of the node.
+type memoryAddr string
+
+// memoryTransport is the transport of a node on the memory network.
+type memoryTransport struct {
+	network *MemoryNetwork
+	addr    string // Listening address of the node, the other side of its connections sees it
+}
+
+// memoryListener accepts the connections dialed to its address.
+type memoryListener struct {
+	network   *MemoryNetwork
+	addr      string
+	conns     chan *memoryConn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// memoryFrame is a write waiting to be read.
+type memoryFrame struct {
+	data []byte
+	due  time.Time
+}
+
+// memoryPipe carries the writes of one side of a connection to the other side.
+type memoryPipe struct {
+	mu           sync.Mutex
+	frames       []memoryFrame // In the order of the writes, which is the order of their due times
+	pending      []byte        // Rest of the frame being read
+	last         time.Time     // Due time of the last write, the next ones are not read before it
+	writerClosed bool          // The writing side closed the connection, the reader gets io.EOF once the frames are read
+	readerClosed bool          // The reading side closed the connection
+	reset        bool          // The connection was reset, the frames are discarded and both sides get ErrConnectionReset
+	deadline     time.Time     // Read deadline of the reading side
+	notify       chan struct{} // Wakes up the reader
+}
+
+// memoryConn is one side of a memory connection.
+type memoryConn struct {
+	network *MemoryNetwork
+	local   string
+	remote  string
+	in      *memoryPipe
+	out     *memoryPipe
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the memory network, without latency, loss or partitions
+ * @param: seed of the random source of the faults
+ * @return: instance of memory network
+ **/
+
+func NewMemoryNetwork(seed int64) *MemoryNetwork {
+	return &MemoryNetwork{
+		seed:      seed,
+		rng:       rand.New(rand.NewSource(seed)),
+		listeners: make(map[string]*memoryListener),
+		groups:    make(map[string]int),
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the transport of the node listening on the address
+ * @param: address string of the node
+ * @return: transport
+ **/
+
+func (mn *MemoryNetwork) Transport(addr string) Transport {
+	return &memoryTransport{network: mn, addr: addr}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to split the network, each group of addresses can only reach itself
+ * @description: Addresses which are in no group form one more partition. A write across partitions resets its connection.
+ * @param: groups of addresses
+ **/
+
+func (mn *MemoryNetwork) Partition(groups ...[]string) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	mn.groups = make(map[string]int)
+	for i, group := range groups {
+		for _, addr := range group {
+			mn.groups[addr] = i + 1
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to remove the partitions, every address can reach every other one again
+ **/
+
+func (mn *MemoryNetwork) Heal() {
+	mn.Partition()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the addresses are in the same partition, it must be called with the lock held
+ * @param: two addresses
+ * @return: bool
+ **/
+
+func (mn *MemoryNetwork) reachable(from string, to string) bool {
+	return mn.groups[from] == mn.groups[to]
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to draw the faults of a write between the addresses
+ * @param: address of the writer, address of the reader
+ * @return: delay of the write, reset bool (the write resets its connection)
+ **/
+
+func (mn *MemoryNetwork) schedule(from string, to string) (time.Duration, bool) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	if !mn.reachable(from, to) || mn.rng.Float64() < mn.ResetRate {
+		return 0, true
+	}
+	delay := mn.Latency
+	if mn.Jitter > 0 {
+		delay += time.Duration(mn.rng.Int63n(int64(mn.Jitter) + 1))
+	}
+	if mn.rng.Float64() < mn.LossRate {
+		delay += memoryRetransmitTimeout
+	}
+	return delay, false
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to listen for the memory connections on the address
+ * @param: address string
+ * @return: listener and error if the address is already in use
+ **/
+
+func (t *memoryTransport) Listen(addr string) (net.Listener, error) {
+	mn := t.network
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	if _, ok := mn.listeners[addr]; ok {
+		return nil, fmt.Errorf("%s: %w", addr, ErrAddressInUse)
+	}
+	ln := &memoryListener{network: mn, addr: addr, conns: make(chan *memoryConn), done: make(chan struct{})}
+	mn.listeners[addr] = ln
+	return ln, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to open a memory connection to the node listening on the address
+ * @param: address string, timeout until the connection is accepted
+ * @return: connection and error if nothing listens on the address, it is in another partition or the timeout passed
+ **/
+
+func (t *memoryTransport) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	mn := t.network
+	mn.mu.Lock()
+	ln, ok := mn.listeners[addr]
+	reachable := mn.reachable(t.addr, addr)
+	mn.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("dial %s: %w", addr, ErrConnectionRefused)
+	}
+	if !reachable {
+		return nil, fmt.Errorf("dial %s: %w", addr, ErrPartitioned)
+	}
+
+	toServer, toClient := newMemoryPipe(), newMemoryPipe()
+	client := &memoryConn{network: mn, local: t.addr, remote: addr, in: toClient, out: toServer}
+	server := &memoryConn{network: mn, local: addr, remote: t.addr, in: toServer, out: toClient}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ln.conns <- server:
+		return client, nil
+	case <-ln.done:
+		return nil, fmt.Errorf("dial %s: %w", addr, ErrConnectionRefused)
+	case <-timer.C:
+		return nil, fmt.Errorf("dial %s: %w", addr, os.ErrDeadlineExceeded)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to accept the next memory connection
+ * @return: connection and net.ErrClosed once the listener is closed
+ **/
+
+func (ln *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-ln.conns:
+		return conn, nil
+	case <-ln.done:
+		return nil, net.ErrClosed
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to stop listening, the address can be listened on again
+ * @return: nil
+ **/
+
+func (ln *memoryListener) Close() error {
+	ln.closeOnce.Do(func() {
+		close(ln.done)
+		ln.network.mu.Lock()
+		if ln.network.listeners[ln.addr] == ln {
+			delete(ln.network.listeners, ln.addr)
+		}
+		ln.network.mu.Unlock()
+	})
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the address of the listener
+ * @return: address
+ **/
+
+func (ln *memoryListener) Addr() net.Addr {
+	return memoryAddr(ln.addr)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the network name of the memory address
+ * @return: string
+ **/
+
+func (a memoryAddr) Network() string {
+	return "memory"
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the memory address as a string
+ * @return: string
+ **/
+
+func (a memoryAddr) String() string {
+	return string(a)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create one direction of a memory connection
+ * @return: instance of memory pipe
+ **/
+
+func newMemoryPipe() *memoryPipe {
+	return &memoryPipe{notify: make(chan struct{}, 1)}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to wake up the reader of the pipe, it must be called with the lock held
+ **/
+
+func (p *memoryPipe) wake() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to queue the write for the reader after the delay, it is not read before the
+ * @description: writes queued before it
+ * @param: data of the write, delay
+ **/
+
+func (p *memoryPipe) push(data []byte, delay time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	due := time.Now().Add(delay)
+	if due.Before(p.last) {
+		due = p.last
+	}
+	p.last = due
+	p.frames = append(p.frames, memoryFrame{data: data, due: due})
+	p.wake()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to reset the direction of the connection, its frames are discarded
+ **/
+
+func (p *memoryPipe) resetPipe() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reset = true
+	p.frames, p.pending = nil, nil
+	p.wake()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read the writes which are due, it blocks until one is due or the deadline passes
+ * @param: buffer
+ * @return: number of bytes read and error if any
+ **/
+
+func (c *memoryConn) Read(b []byte) (int, error) {
+	p := c.in
+	for {
+		p.mu.Lock()
+		if p.readerClosed {
+			p.mu.Unlock()
+			return 0, net.ErrClosed
+		}
+		if p.reset {
+			p.mu.Unlock()
+			return 0, ErrConnectionReset
+		}
+		if len(p.pending) > 0 {
+			n := copy(b, p.pending)
+			p.pending = p.pending[n:]
+			p.mu.Unlock()
+			return n, nil
+		}
+		now := time.Now()
+		if len(p.frames) > 0 && !p.frames[0].due.After(now) {
+			p.pending = p.frames[0].data
+			p.frames = p.frames[1:]
+			p.mu.Unlock()
+			continue
+		}
+		if p.writerClosed && len(p.frames) == 0 {
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		if !p.deadline.IsZero() && !p.deadline.After(now) {
+			p.mu.Unlock()
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		wait := time.Duration(-1)
+		if len(p.frames) > 0 {
+			wait = p.frames[0].due.Sub(now)
+		}
+		if !p.deadline.IsZero() && (wait < 0 || p.deadline.Sub(now) < wait) {
+			wait = p.deadline.Sub(now)
+		}
+		p.mu.Unlock()
+
+		if wait < 0 {
+			<-p.notify
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-p.notify:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the write to the other side, after the delay drawn by the fault injection
+ * @description: A write which the fault injection resets, or which crosses partitions, resets the connection.
+ * @param: data
+ * @return: number of bytes written and error if the connection is closed or reset
+ **/
+
+func (c *memoryConn) Write(b []byte) (int, error) {
+	c.in.mu.Lock()
+	closed, reset := c.in.readerClosed, c.in.reset
+	c.in.mu.Unlock()
+	if closed {
+		return 0, net.ErrClosed
+	}
+	if reset {
+		return 0, ErrConnectionReset
+	}
+	c.out.mu.Lock()
+	closed = c.out.readerClosed
+	c.out.mu.Unlock()
+	if closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	delay, reset := c.network.schedule(c.local, c.remote)
+	if reset {
+		c.in.resetPipe()
+		c.out.resetPipe()
+		return 0, fmt.Errorf("write to %s: %w", c.remote, ErrConnectionReset)
+	}
+	c.out.push(append([]byte(nil), b...), delay)
+	return len(b), nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to close the connection, the other side reads io.EOF once it read the pending writes
+ * @return: nil
+ **/
+
+func (c *memoryConn) Close() error {
+	c.in.mu.Lock()
+	c.in.readerClosed = true
+	c.in.wake()
+	c.in.mu.Unlock()
+
+	c.out.mu.Lock()
+	c.out.writerClosed = true
+	c.out.wake()
+	c.out.mu.Unlock()
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the address of the local node
+ * @return: address
+ **/
+
+func (c *memoryConn) LocalAddr() net.Addr {
+	return memoryAddr(c.local)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the address of the remote node
+ * @return: address
+ **/
+
+func (c *memoryConn) RemoteAddr() net.Addr {
+	return memoryAddr(c.remote)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to set the read deadline, writes never block on the memory network
+ * @param: deadline, zero for none
+ * @return: nil
+ **/
+
+func (c *memoryConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to set the read deadline, a blocked read returns os.ErrDeadlineExceeded once it passes
+ * @param: deadline, zero for none
+ * @return: nil
+ **/
+
+func (c *memoryConn) SetReadDeadline(t time.Time) error {
+	c.in.mu.Lock()
+	defer c.in.mu.Unlock()
+	c.in.deadline = t
+	c.in.wake()
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to satisfy net.Conn, writes never block on the memory network
+ * @param: deadline
+ * @return: nil
+ **/
+
+func (c *memoryConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
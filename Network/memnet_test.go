@@ -0,0 +1,118 @@
+package network
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to open a memory connection between two addresses of the network
+ * @param: testing instance, memory network
+ * @return: dialing side and accepted side of the connection
+ **/
+
+func dialTestMemoryConn(t *testing.T, memnet *MemoryNetwork) (*memoryConn, *memoryConn) {
+	t.Helper()
+	ln, err := memnet.Transport("127.0.0.1:30901").Listen("127.0.0.1:30901")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	accepted := make(chan *memoryConn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn.(*memoryConn)
+		}
+		close(accepted)
+	}()
+	conn, err := memnet.Transport("127.0.0.1:30900").Dial("127.0.0.1:30901", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn.(*memoryConn), <-accepted
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that the memory network keeps the byte stream whole: with jitter and lost writes,
+ * @description: every write arrives, in order
+ **/
+
+func TestMemoryConnKeepsStreamOrder(t *testing.T) {
+	memnet := NewMemoryNetwork(testSimulationSeed)
+	memnet.Jitter = 2 * time.Millisecond
+	memnet.LossRate = 0.2
+	client, server := dialTestMemoryConn(t, memnet)
+
+	var sent bytes.Buffer
+	for i := 0; i < 200; i++ {
+		write := []byte(fmt.Sprintf("write %d;", i))
+		sent.Write(write)
+		if _, err := client.Write(write); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.Close()
+	received, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, sent.Bytes()) {
+		t.Fatalf("received %d bytes which differ from the %d bytes written", len(received), sent.Len())
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that a reset and a partition close the connection on both sides, instead of
+ * @description: dropping writes from the middle of the stream
+ **/
+
+func TestMemoryConnReset(t *testing.T) {
+	memnet := NewMemoryNetwork(testSimulationSeed)
+	client, server := dialTestMemoryConn(t, memnet)
+	memnet.Partition([]string{"127.0.0.1:30900"})
+	if _, err := client.Write([]byte("across the partition")); !errors.Is(err, ErrConnectionReset) {
+		t.Fatalf("write across partitions returned %v, want %v", err, ErrConnectionReset)
+	}
+	if _, err := server.Read(make([]byte, 16)); !errors.Is(err, ErrConnectionReset) {
+		t.Fatalf("read of the reset connection returned %v, want %v", err, ErrConnectionReset)
+	}
+	if _, err := server.Write([]byte("reply")); !errors.Is(err, ErrConnectionReset) {
+		t.Fatalf("write on the reset connection returned %v, want %v", err, ErrConnectionReset)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that the nodes of the memory network run TLS and identify their peers by the key
+ * @description: of the certificate, as over TCP
+ **/
+
+func TestMemoryNetworkRunsTLS(t *testing.T) {
+	nodes, stop, err := startSimulationNodes(2, 30950, newTestMemoryNetwork(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if err := nodes[0].AddPeer(nodes[1].Address); err != nil {
+		t.Fatal(err)
+	}
+	peers := nodes[0].Peers()
+	if len(peers) != 1 {
+		t.Fatalf("node has %d peers, want 1", len(peers))
+	}
+	if _, ok := peers[0].conn.(*tls.Conn); !ok {
+		t.Fatalf("peer connection is a %T, want a TLS connection", peers[0].conn)
+	}
+	if peers[0].ID != nodes[1].ID() {
+		t.Errorf("peer has node ID %s, want %s", peers[0].ID, nodes[1].ID())
+	}
+}
@@ -0,0 +1,195 @@
+package network
+
+import (
+	"errors"
+	"log"
+	"net"
+)
+
+const banThreshold = 100 // A peer whose misbehavior score reaches this is disconnected and banned
+
+// Misbehavior penalties, added to the score of the peer
+const (
+	penaltyMalformedMessage  = 20  // A payload which cannot be decoded
+	penaltyUnknownMessage    = 10  // A message type which is unknown or not allowed after the handshake
+	penaltyProtocolViolation = 20  // A request or reply which breaks the protocol
+	penaltyInvalidFrame      = 50  // A frame with a bad magic, checksum or size
+	penaltyInvalidBlock      = 100 // A block with a bad hash, Merkle root or proof of work
+	penaltyRateLimited       = 5   // A message over the message rate limit, it is dropped
+)
+
+var ErrPeerBanned = errors.New("peer is banned")
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add the penalty to the misbehavior score of the peer
+ * @description: The score is kept by node ID, so the peer cannot shed it by announcing another address. A peer reaching
+ * @description: banThreshold is banned for BanDuration and disconnected.
+ * @param: address of the peer, penalty int, reason string
+ **/
+
+func (n *Node) Misbehaving(addr string, penalty int, reason string) {
+	if addr == "" || penalty <= 0 {
+		return
+	}
+	n.mu.Lock()
+	peer, ok := n.peerAt(addr)
+	if !ok {
+		n.mu.Unlock()
+		return // Only connected peers are scored, the node ID of anyone else is unknown
+	}
+	n.scores[peer.ID] += penalty
+	score := n.scores[peer.ID]
+	n.mu.Unlock()
+
+	log.Printf("Peer %s misbehaving (%s): score %d\n", addr, reason, score)
+	if score >= banThreshold {
+		if err := n.BanPeer(peer.ID.String(), reason); err != nil {
+			log.Println("Error saving the ban list:", err)
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the misbehavior score of the peer
+ * @param: address or node ID of the peer
+ * @return: score int
+ **/
+
+func (n *Node) MisbehaviorScore(key string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if peer := n.peerByKey(key); peer != nil {
+		return n.scores[peer.ID]
+	}
+	var id NodeID
+	if id.UnmarshalText([]byte(key)) != nil {
+		return 0
+	}
+	return n.scores[id]
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to ban the peer for BanDuration and disconnect it
+ * @description: A connected peer is banned by its node ID and by the IP address its connection comes from. Loopback IP
+ * @description: addresses are not banned, every node of a local cluster shares them, so a loopback peer is banned by the
+ * @description: address it announced instead; elsewhere that address is not banned, another node may own it. A peer
+ * @description: which comes back with a new key and a new port still gets in. Any other key is banned as given, an
+ * @description: address is then no longer dialed.
+ * @description: The misbehavior score is reset, so the peer starts again from zero once the ban expires.
+ * @param: address or node ID of the peer, reason string
+ * @return: error if the ban list could not be saved
+ **/
+
+func (n *Node) BanPeer(key string, reason string) error {
+	n.mu.Lock()
+	peer := n.peerByKey(key)
+	keys := []string{key}
+	if peer != nil {
+		keys = []string{peer.ID.String()}
+		if ip := peer.RemoteIP(); !isLoopback(ip) {
+			keys = append(keys, ip)
+		} else if peer.Address() != "" {
+			keys = append(keys, peer.Address())
+		}
+		delete(n.scores, peer.ID)
+	}
+	n.mu.Unlock()
+
+	var err error
+	for _, banned := range keys {
+		if banErr := n.BanList.Ban(banned, reason, n.BanDuration); err == nil {
+			err = banErr
+		}
+	}
+	if peer != nil {
+		peer.Close()
+		n.RoutingTable().Remove(peer.ID)
+	}
+	log.Printf("Banned peer %s for %v: %s\n", key, n.BanDuration, reason)
+	return err
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to lift the ban of the peer and reset its misbehavior score
+ * @param: node ID, IP address or address of the peer
+ * @return: false if it was not banned, error if the ban list could not be saved
+ **/
+
+func (n *Node) UnbanPeer(key string) (bool, error) {
+	var id NodeID
+	if id.UnmarshalText([]byte(key)) == nil {
+		n.mu.Lock()
+		delete(n.scores, id)
+		n.mu.Unlock()
+	}
+	return n.BanList.Unban(key)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the connected peer by its address or node ID, n.mu must be held
+ * @param: address or node ID of the peer
+ * @return: instance of peer, nil if it is not connected
+ **/
+
+func (n *Node) peerByKey(key string) *Peer {
+	if peer, ok := n.peerAt(key); ok {
+		return peer
+	}
+	var id NodeID
+	if id.UnmarshalText([]byte(key)) != nil {
+		return nil
+	}
+	return n.peers[id]
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the peer which completed the handshake is banned
+ * @description: A loopback peer is also checked by the address it announced, see BanPeer.
+ * @param: version of the peer, its connection
+ * @return: bool
+ **/
+
+func (n *Node) isBannedPeer(version VersionInfo, conn net.Conn) bool {
+	keys := []string{NodeIDFromKey(version.PublicKey).String(), remoteIP(conn)}
+	if isLoopback(remoteIP(conn)) && version.Address != "" {
+		keys = append(keys, version.Address)
+	}
+	return n.BanList.IsBanned(keys...)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if an address must not be dialed, because it or its IP address is banned
+ * @param: address of the peer
+ * @return: bool
+ **/
+
+func (n *Node) isBannedAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return n.BanList.IsBanned(addr)
+	}
+	return n.BanList.IsBanned(addr, host)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to decode the payload of the message, a malformed payload is penalized
+ * @param: instance of message, pointer to the payload
+ * @return: false if the payload could not be decoded
+ **/
+
+func (n *Node) decodePayload(msg *Message, payload interface{}) bool {
+	if err := msg.DecodePayload(payload); err != nil {
+		log.Printf("Error decoding %s message from %s: %v\n", msg.Type, msg.From, err)
+		n.Misbehaving(msg.From, penaltyMalformedMessage, "malformed "+msg.Type+" message")
+		return false
+	}
+	return true
+}
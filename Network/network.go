@@ -1,112 +1,514 @@
-package network
-
-import (
-	"encoding/json"
-	"log"
-	"net"
-
-	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
-)
-
-type Node struct {
-	Blockchain *MidLevelBlockchain.Blockchain
-	Address    string // Node's network address
-	// Additional networking properties will be added later
-}
-
-/**
- * @createdby: Syed Muhammad Ammar
- * @description: This function is used to encode the message
- * @param: instance of message in byte slice
- * @return: byte slice and error if any
- **/
-
-func EncodeMessage(msg *Message) ([]byte, error) {
-	return json.Marshal(msg)
-}
-
-/**
- * @createdby: Syed Muhammad Ammar
- * @description: This function is used to decode the message
- * @param: byte slice of message
- * @return: instance of message and error if any
- **/
-
-func DecodeMessage(data []byte) (*Message, error) {
-	var msg Message
-	err := json.Unmarshal(data, &msg)
-	return &msg, err
-}
-
-/**
- * @createdby: Syed Muhammad Ammar
- * @description: This function is used to start the server for now it is listening on the port 8001 and 8002
- **/
-func (n *Node) StartServer() {
-	ln, err := net.Listen("tcp", n.Address)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer ln.Close()
-
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-		go n.handleConnection(conn)
-	}
-}
-
-/**
- * @createdby: Syed Muhammad Ammar
- * @description: This function is used to handle the connection which is established between the nodes
- * @param: instance of connection
- **/
-
-func (n *Node) handleConnection(conn net.Conn) {
-	defer conn.Close()
-	buf := make([]byte, 1024) // Adjust buffer size as needed
-
-	length, err := conn.Read(buf)
-	if err != nil {
-		log.Println("Error reading:", err.Error())
-		return
-	}
-
-	msg, err := DecodeMessage(buf[:length])
-	if err != nil {
-		log.Println("Error decoding message:", err)
-		return
-	}
-
-	n.handleMessage(msg)
-}
-
-/**
- * @createdby: Syed Muhammad Ammar
- * @description: This function is used to send the message to the other nodes
- * @param: address of the node and instance of message
- **/
-
-func (n *Node) sendMessage(addr string, msg *Message) {
-	conn, err := net.Dial("tcp", addr)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	defer conn.Close()
-
-	encodedMsg, err := EncodeMessage(msg)
-	if err != nil {
-		log.Println("Error encoding message:", err)
-		return
-	}
-
-	_, err = conn.Write(encodedMsg)
-	if err != nil {
-		log.Println("Error sending message:", err)
-		return
-	}
-}
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+type Node struct {
+	Blockchain      *MidLevelBlockchain.Blockchain
+	Mempool         *MidLevelBlockchain.Mempool // Pending transactions waiting to be mined
+	Address         string                      // Node's network address
+	ChainID         string                      // Only peers on the same chain are accepted
+	AddressBook     *AddressBook                // Addresses of the nodes which can be connected to
+	MaxOutbound     int                         // Outbound connections opened by the peer discovery
+	MaxInbound      int                         // Inbound connections accepted at the same time
+	MaxInboundPerIP int                         // Inbound connections accepted at the same time from a single IP address, loopback excepted
+	Identity        ed25519.PrivateKey          // Identity key of the node, see SetIdentity
+	BanList         *BanList                    // Peers refused because they misbehaved
+	BanDuration     time.Duration               // How long a misbehaving peer is banned
+	Transport       Transport                   // Opens the connections of the node, TCP unless a simulation replaces it
+	TrustedKeys     map[NodeID]bool             // Only peers with these keys are accepted when it is not empty, see ParseTrustedKeys
+	Messages        *MessageRegistry            // Message types of the node and their handlers, applications may add theirs
+	Codecs          []string                    // Codecs offered to the peers in the handshake, most preferred first
+	PingInterval    time.Duration               // Time between two pings of each peer, zero disables the pings
+	PongTimeout     time.Duration               // A peer which does not answer a ping for this long is disconnected
+	// Additional networking properties will be added later
+
+	mu           sync.Mutex
+	producerStop chan struct{}            // Closed to stop the automatic block producer
+	peerServices map[string]NodeServices  // Services advertised by the peers, by address
+	peers        map[NodeID]*Peer         // Connected peers, by node ID
+	peerIDs      map[string]NodeID        // Node IDs of the connected peers, by address, only used to reach a peer by its address
+	dialing      map[string]*dialCall     // Connections being opened, by address
+	removed      map[string]bool          // Peers removed by the user, they are not dialed again
+	dht          *RoutingTable            // Contacts of the DHT
+	requests     map[uint64]chan *Message // Requests waiting for their response, by request ID
+	sync         syncState                // Initial block download
+	seen         *seenCache               // Blocks and transactions already processed
+	inFlight     map[string]time.Time     // Items requested with GetData, by type and hash
+	compact      map[string]*partialBlock // Compact blocks waiting for their missing transactions, by hash
+	receipts     map[string]*Broadcast    // Broadcasts of the blocks mined by the node, waiting for the peers to reply, by hash
+	traffic      trafficStats             // Frames sent, by message type
+	scores       map[NodeID]int           // Misbehavior scores of the peers, by node ID
+	certificate  tls.Certificate          // Self-signed certificate of the identity key, see SetIdentity
+	inbound      int                      // Inbound connections open, handshakes included
+	inboundByIP  map[string]int           // Inbound connections open, by IP address
+	protocol     int                      // Protocol version announced to the peers, ProtocolVersion unless a test plays an older node
+
+	lifeMu   sync.Mutex         // Guards the fields of the lifecycle below
+	running  bool               // Between Start and Stop
+	ctx      context.Context    // Cancelled when the node stops
+	cancel   context.CancelFunc // Cancels ctx
+	listener net.Listener       // Accepts the peers while the node is running
+	wg       sync.WaitGroup     // Background loops, connections and peers, Stop waits for them
+
+	outboxMu sync.Mutex
+	outboxes map[string]*outbox // Messages waiting to be delivered, by peer address
+
+	nextRequestID uint64
+}
+
+// dialCall lets concurrent senders to the same address share a single connection attempt.
+type dialCall struct {
+	done chan struct{}
+	peer *Peer
+	err  error
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the new node
+ * @param: instance of blockchain, instance of mempool, address string on which the node listens
+ * @return: instance of node
+ **/
+
+func NewNode(bc *MidLevelBlockchain.Blockchain, mempool *MidLevelBlockchain.Mempool, address string) *Node {
+	n := &Node{
+		Blockchain:      bc,
+		Mempool:         mempool,
+		Address:         address,
+		ChainID:         DefaultChainID,
+		AddressBook:     NewAddressBook(""),
+		Transport:       TCPTransport{},
+		MaxOutbound:     DefaultMaxOutbound,
+		MaxInbound:      DefaultMaxInbound,
+		MaxInboundPerIP: DefaultMaxInboundPerIP,
+		BanList:         NewBanList(""),
+		BanDuration:     DefaultBanDuration,
+		peerServices:    make(map[string]NodeServices),
+		peers:           make(map[NodeID]*Peer),
+		peerIDs:         make(map[string]NodeID),
+		dialing:         make(map[string]*dialCall),
+		removed:         make(map[string]bool),
+		requests:        make(map[uint64]chan *Message),
+		seen:            newSeenCache(seenCacheSize),
+		inFlight:        make(map[string]time.Time),
+		compact:         make(map[string]*partialBlock),
+		receipts:        make(map[string]*Broadcast),
+		scores:          make(map[NodeID]int),
+		inboundByIP:     make(map[string]int),
+		outboxes:        make(map[string]*outbox),
+		Messages:        NewDefaultMessageRegistry(),
+		Codecs:          DefaultCodecs,
+		PingInterval:    DefaultPingInterval,
+		PongTimeout:     DefaultPongTimeout,
+		protocol:        ProtocolVersion,
+	}
+	n.SetIdentity(GenerateIdentity()) // Replaced by the persistent key of the node, if any
+	n.registerMessageHandlers()
+	return n
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the message
+ * @param: instance of message in byte slice
+ * @return: byte slice and error if any
+ **/
+
+func EncodeMessage(msg *Message) ([]byte, error) {
+	return encodeMessage(msg, JSONCodec{})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the message with the codec, the payload of the message, if any, is encoded
+ * @description: into its data first. The message itself is not changed, it may be sent to several peers at once.
+ * @param: instance of message, codec
+ * @return: byte slice and error if any
+ **/
+
+func encodeMessage(msg *Message, codec Codec) ([]byte, error) {
+	wire := *msg
+	if msg.Payload != nil {
+		data, err := codec.Marshal(msg.Payload)
+		if err != nil {
+			return nil, err
+		}
+		wire.Data = data
+	}
+	return codec.Marshal(&wire)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to decode the message
+ * @param: byte slice of message
+ * @return: instance of message and error if any
+ **/
+
+func DecodeMessage(data []byte) (*Message, error) {
+	return decodeMessage(data, JSONCodec{})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to decode the message with the codec, its payload is decoded later with the same codec
+ * @param: byte slice of message, codec
+ * @return: instance of message and error if any
+ **/
+
+func decodeMessage(data []byte, codec Codec) (*Message, error) {
+	msg := &Message{codec: codec}
+	err := codec.Unmarshal(data, msg)
+	return msg, err
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to accept the connections of the listener until it is closed
+ * @param: listener
+ **/
+
+func (n *Node) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if !n.acquireInbound(conn) {
+			conn.Close()
+			continue
+		}
+		started := n.spawn(func() {
+			defer n.releaseInbound(conn)
+			n.handleConnection(conn)
+		})
+		if !started {
+			n.releaseInbound(conn)
+			conn.Close()
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to handle the connection which is established between the nodes
+ * @description: The peer has to complete the TLS and version handshakes, then the connection is kept open as a peer.
+ * @description: It returns when the connection is closed, so the connection keeps its inbound slot until then.
+ * @param: instance of connection
+ **/
+
+func (n *Node) handleConnection(rawConn net.Conn) {
+	if n.BanList.IsBanned(remoteIP(rawConn)) {
+		log.Printf("Refusing connection from banned address %s\n", rawConn.RemoteAddr())
+		rawConn.Close()
+		return
+	}
+	conn, err := n.secureConn(rawConn, true)
+	if err != nil {
+		log.Printf("Refusing connection from %s: %v\n", rawConn.RemoteAddr(), err)
+		rawConn.Close()
+		return
+	}
+	version, err := n.handshake(conn, true)
+	if err != nil {
+		log.Printf("Refusing connection from %s: %v\n", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	if n.isRemoved(version.Address) {
+		log.Printf("Refusing connection from removed peer %s\n", version.Address)
+		conn.Close()
+		return
+	}
+	if n.isBannedPeer(version, conn) {
+		log.Printf("Refusing connection from banned peer %s\n", version.Address)
+		conn.Close()
+		return
+	}
+
+	peer := newPeer(n, conn, true, version)
+	if _, err := n.addPeer(peer); err != nil {
+		log.Printf("Refusing connection from %s: %v\n", conn.RemoteAddr(), err)
+		return
+	}
+	<-peer.done
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the message to the other nodes
+ * @description: The message is queued in the outbox of the node, which delivers it in the background with retries.
+ * @param: address of the node and instance of message
+ **/
+
+func (n *Node) sendMessage(addr string, msg *Message) {
+	n.SendReliable(addr, msg)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the connected peer with the address, or to connect to it
+ * @param: address of the node
+ * @return: instance of peer and error if the connection or the handshake failed
+ **/
+
+func (n *Node) connectPeer(addr string) (*Peer, error) {
+	n.mu.Lock()
+	if peer, ok := n.peerAt(addr); ok {
+		n.mu.Unlock()
+		return peer, nil
+	}
+	if call, ok := n.dialing[addr]; ok {
+		n.mu.Unlock()
+		<-call.done
+		return call.peer, call.err
+	}
+	call := &dialCall{done: make(chan struct{})}
+	n.dialing[addr] = call
+	n.mu.Unlock()
+
+	call.peer, call.err = n.dialPeer(addr)
+
+	n.mu.Lock()
+	delete(n.dialing, addr)
+	n.mu.Unlock()
+	close(call.done)
+	return call.peer, call.err
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to open a connection to the node and run the handshake
+ * @param: address of the node
+ * @return: instance of peer and error if any
+ **/
+
+func (n *Node) dialPeer(addr string) (*Peer, error) {
+	if !n.Running() {
+		return nil, ErrNodeStopped
+	}
+	if n.isBannedAddress(addr) {
+		return nil, fmt.Errorf("%s: %w", addr, ErrPeerBanned)
+	}
+	rawConn, err := n.Transport.Dial(addr, handshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := n.secureConn(rawConn, false)
+	if err != nil {
+		rawConn.Close()
+		return nil, fmt.Errorf("TLS handshake with %s failed: %w", addr, err)
+	}
+	version, err := n.handshake(conn, false)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("handshake with %s failed: %w", addr, err)
+	}
+	if version.Address != addr {
+		conn.Close()
+		return nil, fmt.Errorf("handshake with %s failed: %w: %s", addr, ErrAddressMismatch, version.Address)
+	}
+	if n.isBannedPeer(version, conn) {
+		conn.Close()
+		return nil, fmt.Errorf("%s: %w", addr, ErrPeerBanned)
+	}
+	return n.addPeer(newPeer(n, conn, false, version))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to register the peer by its node ID and start its loops
+ * @description: If both nodes connected to each other at the same time, both of them keep the connection
+ * @description: opened by the node with the lower node ID and close the other one. The address announced by an inbound
+ * @description: peer is not proven, so it is refused if the address belongs to another connected node. A peer we dialed
+ * @description: proved its address, it takes the address over from a peer which claimed it.
+ * @param: instance of peer
+ * @return: the peer which is kept for the node ID and error if the peer was refused
+ **/
+
+func (n *Node) addPeer(peer *Peer) (*Peer, error) {
+	addr := peer.Address()
+	localID := n.ID()
+	preferOutbound := bytes.Compare(localID[:], peer.ID[:]) < 0
+
+	n.mu.Lock()
+	impostor, claimed := n.peerAt(addr)
+	if claimed && (impostor.ID == peer.ID || impostor.Closed()) {
+		impostor, claimed = nil, false
+	}
+	if claimed && peer.Inbound {
+		n.mu.Unlock()
+		peer.Close()
+		return nil, fmt.Errorf("%w: %s", ErrAddressClaimed, addr)
+	}
+	existing, ok := n.peers[peer.ID]
+	if ok && !existing.Closed() && existing.Inbound != peer.Inbound && peer.Inbound == preferOutbound {
+		n.mu.Unlock()
+		peer.Close()
+		return existing, nil
+	}
+	if ok && n.peerIDs[existing.Address()] == peer.ID {
+		delete(n.peerIDs, existing.Address())
+	}
+	n.peers[peer.ID] = peer
+	n.peerIDs[addr] = peer.ID
+	n.peerServices[addr] = peer.Version.Services
+	n.mu.Unlock()
+
+	if ok {
+		existing.Close()
+		existing.transferQueue(peer)
+	}
+	if claimed {
+		log.Printf("Peer %s claimed the address %s of peer %s, disconnecting it\n", impostor.ID.Short(), addr, peer.ID.Short())
+		impostor.Close()
+	}
+	n.AddressBook.Add(addr, "peer")
+	n.AddressBook.MarkGood(addr)
+	if contact, ok := n.peerContact(addr); ok {
+		n.dhtSeen(contact)
+	}
+	peer.start()
+	if peer.Closed() {
+		return peer, nil
+	}
+	if !peer.Inbound {
+		n.requestAddresses(peer)
+	}
+	if height := n.Blockchain.Height(); peer.Version.BestHeight > height {
+		go n.checkSync()
+	} else if height > peer.Version.BestHeight {
+		// The blocks mined during the handshake were broadcast before the peer was registered, it is told the new height
+		peer.Send(&Message{Type: "Services", Payload: n.LocalServices(), From: n.Address})
+	}
+	log.Printf("Connected to peer %s (%s, height %d, inbound: %t)\n", addr, peer.Version.UserAgent, peer.Version.BestHeight, peer.Inbound)
+	return peer, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to unregister the peer once its connection is closed
+ * @param: instance of peer
+ **/
+
+func (n *Node) removePeer(peer *Peer) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.peers[peer.ID] == peer {
+		delete(n.peers, peer.ID)
+		if n.peerIDs[peer.Address()] == peer.ID {
+			delete(n.peerIDs, peer.Address())
+		}
+		log.Printf("Disconnected from peer %s\n", peer.Address())
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the connected peer which is reached at the address, n.mu must be held
+ * @param: address of the peer
+ * @return: instance of peer and false if no connected peer has the address
+ **/
+
+func (n *Node) peerAt(addr string) (*Peer, bool) {
+	id, ok := n.peerIDs[addr]
+	if !ok {
+		return nil, false
+	}
+	peer, ok := n.peers[id]
+	return peer, ok
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to close the connection of the peer, it may connect again later
+ * @param: address of the peer
+ **/
+
+func (n *Node) disconnect(addr string) {
+	n.mu.Lock()
+	peer, _ := n.peerAt(addr)
+	n.mu.Unlock()
+	if peer != nil {
+		peer.Close()
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the connected peers
+ * @return: slice of peers
+ **/
+
+func (n *Node) Peers() []*Peer {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	peers := make([]*Peer, 0, len(n.peers))
+	for _, peer := range n.peers {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the protocol version spoken with the sender of a message
+ * @param: address of the peer
+ * @return: protocol version, the version of the node if the sender is not a connected peer
+ **/
+
+func (n *Node) peerProtocol(addr string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if peer, ok := n.peerAt(addr); ok {
+		return peer.Protocol
+	}
+	return ProtocolVersion
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the protocol version announced by a peer in its handshake
+ * @param: address of the peer
+ * @return: protocol version, zero if the peer is not connected
+ **/
+
+func (n *Node) peerAnnouncedVersion(addr string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if peer, ok := n.peerAt(addr); ok {
+		return peer.Version.ProtocolVersion
+	}
+	return 0
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the addresses a broadcast is sent to, i.e. the connected peers
+ * @return: slice of addresses
+ **/
+
+func (n *Node) broadcastAddresses() []string {
+	var addrs []string
+	for _, peer := range n.Peers() {
+		addrs = append(addrs, peer.Address())
+	}
+	return addrs
+}
@@ -1,108 +1,154 @@
-package network
-
-import (
-	"encoding/json"
-	"log"
-
-	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
-)
-
-var knownNodes = []string{"localhost:8001", "localhost:8002"} // example addresses
-type Message struct {
-	Type string // e.g., "NewBlock", "NewTransaction"
-	Data []byte // Encoded data (block, transaction, etc.)
-}
-
-/*
- * @createdby: Syed Muhammad Ammar
- * @description: This function is used to send the message to the node
- * @param: address of the node and instance of message
- * @return: nil
- */
-func (n *Node) BroadcastNewBlock(block *MidLevelBlockchain.Block) {
-	encodedBlock, err := json.Marshal(block) // Encoding the block
-	if err != nil {
-		log.Println("Error encoding block:", err)
-		return
-	}
-
-	msg := &Message{
-		Type: "NewBlock",
-		Data: encodedBlock,
-	}
-
-	for _, nodeAddr := range knownNodes {
-		if nodeAddr != n.Address { // Avoid sending it to itself
-			go n.sendMessage(nodeAddr, msg) // Send the encoded block to each known node
-		}
-	}
-}
-
-/*
- * @createdby: Syed Muhammad Ammar
- * @description: This function is used to validate the block that is received from the node
- * @param: instance of block
- */
-func (n *Node) validateBlock(block *MidLevelBlockchain.Block) bool {
-
-	calculatedHash := block.CalculateHash()
-	// Get the last block's hash from the current blockchain for comparison
-	var lastBlockHash string
-	if len(n.Blockchain.Blocks) > 0 {
-		lastBlockHash = n.Blockchain.Blocks[len(n.Blockchain.Blocks)-1].CurrentHash
-	}
-	return block.CurrentHash == calculatedHash && block.PreviousHash == lastBlockHash
-}
-
-/*
- * @createdby: Syed Muhammad Ammar
- * @description: This function is used to send the message to the node
- * @param: address of the node and instance of message
- */
-
-func (n *Node) handleMessage(msg *Message) {
-	switch msg.Type {
-	case "NewBlock":
-		var block MidLevelBlockchain.Block
-		err := json.Unmarshal(msg.Data, &block)
-		if err != nil {
-			log.Println("Error decoding block:", err)
-			return
-		}
-		// Validate the block's hash and the previous hash
-		log.Println("Validating block...")
-		if n.validateBlock(&block) {
-			n.Blockchain.Blocks = append(n.Blockchain.Blocks, &block) // Add the block to the blockchain
-			log.Println("New block added")
-		} else {
-			log.Println("Invalid block received or previous hash does not match")
-		}
-
-		// For future implemenation into depth like transation cache and block cache
-	}
-}
-
-/*
- * @createdby: Syed Muhammad Ammar
- * @description: This function is used to send the broadcast the new transaction to the node
- * @param: address of the node and instance of message
- */
-
-func (n *Node) BroadcastNewTransaction(transaction string) {
-	encodedTx, err := json.Marshal(transaction) // Encoding the transaction
-	if err != nil {
-		log.Println("Error encoding transaction:", err)
-		return
-	}
-
-	msg := &Message{
-		Type: "NewTransaction",
-		Data: encodedTx,
-	}
-
-	for _, nodeAddr := range knownNodes {
-		if nodeAddr != n.Address { // Avoid sending it to itself
-			go n.sendMessage(nodeAddr, msg)
-		}
-	}
-}
+package network
+
+import (
+	"errors"
+	"log"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+type Message struct {
+	Type      string      // Name of a registered message type, e.g., "NewBlock", "NewTransaction"
+	Data      []byte      // Encoded data (block, transaction, etc.)
+	From      string      // Listening address of the sending node
+	RequestID uint64      `json:",omitempty"` // Matches a response to its request, zero for other messages
+	Payload   interface{} `json:"-"`          // Data to send, encoded into Data with the codec of each peer
+	codec     Codec       // Codec which encoded Data, JSON if nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to decode the data of the received message with the codec of its connection
+ * @param: pointer to the payload
+ * @return: error if any
+ **/
+
+func (msg *Message) DecodePayload(payload interface{}) error {
+	codec := msg.codec
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return codec.Unmarshal(msg.Data, payload)
+}
+
+/*
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the message to the node
+ * @param: address of the node and instance of message
+ * @return: instance of broadcast, Wait reports the peers the block was sent to and those which replied
+ */
+func (n *Node) BroadcastNewBlock(block *MidLevelBlockchain.Block) *Broadcast {
+	// Peers get a compact block, or only the hash, and request what they do not have
+	n.markSeen(InvItem{Type: InvBlock, Hash: block.CurrentHash})
+	return n.relayBlock(block, "")
+}
+
+/*
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to register the handlers of the built-in message types
+ */
+
+func (n *Node) registerMessageHandlers() {
+	handleBlock := func(msg *Message, block *MidLevelBlockchain.Block) {
+		// Validate the block (hash, Merkle root, difficulty and previous hash) and add it to the blockchain
+		if err := n.processBlock(block, msg.From); err != nil {
+			log.Println("Block rejected:", err)
+		}
+	}
+	HandlePayload(n.Messages, "NewBlock", handleBlock)
+	HandlePayload(n.Messages, "Block", handleBlock)
+
+	handleTransaction := func(msg *Message, transaction *string) {
+		n.processTransaction(*transaction, msg.From)
+	}
+	HandlePayload(n.Messages, "NewTransaction", handleTransaction)
+	HandlePayload(n.Messages, "Tx", handleTransaction)
+
+	HandlePayload(n.Messages, "Services", func(msg *Message, services *NodeServices) {
+		n.setPeerServices(msg.From, *services)
+		if services.Height > n.Blockchain.Height() {
+			n.checkSync()
+		}
+	})
+	HandlePayload(n.Messages, "Ping", func(msg *Message, ping *PingPayload) {
+		n.handlePing(msg.From, *ping)
+	})
+	HandlePayload(n.Messages, "Pong", func(msg *Message, pong *PingPayload) {
+		n.handlePong(msg.From, *pong)
+	})
+	n.Messages.Handle("GetAddr", func(msg *Message) {
+		n.sendAddresses(msg.From)
+	})
+	HandlePayload(n.Messages, "Addr", func(msg *Message, payload *AddrPayload) {
+		n.receiveAddresses(*payload)
+	})
+	HandlePayload(n.Messages, "GetBlocks", func(msg *Message, payload *GetBlocksPayload) {
+		n.handleGetBlocks(msg, *payload)
+	})
+
+	inventory := func(handler func(msg *Message, payload InvPayload)) func(msg *Message, payload *InvPayload) {
+		return func(msg *Message, payload *InvPayload) {
+			if len(payload.Items) > maxInvItems {
+				n.Misbehaving(msg.From, penaltyProtocolViolation, "oversized "+msg.Type+" message")
+				return
+			}
+			handler(msg, *payload)
+		}
+	}
+	HandlePayload(n.Messages, "Inv", inventory(n.handleInv))
+	HandlePayload(n.Messages, "GetData", inventory(func(msg *Message, payload InvPayload) {
+		n.handleGetData(msg.From, payload)
+	}))
+	HandlePayload(n.Messages, "NotFound", inventory(func(msg *Message, payload InvPayload) {
+		n.handleNotFound(msg.From, payload)
+	}))
+
+	HandlePayload(n.Messages, "CmpctBlock", func(msg *Message, payload *CompactBlockPayload) {
+		n.handleCompactBlock(msg.From, *payload)
+	})
+	HandlePayload(n.Messages, "GetBlockTxn", func(msg *Message, payload *GetBlockTxnPayload) {
+		n.handleGetBlockTxn(msg.From, *payload)
+	})
+	HandlePayload(n.Messages, "BlockTxn", func(msg *Message, payload *BlockTxnPayload) {
+		n.handleBlockTxn(msg.From, *payload)
+	})
+	for _, name := range []string{"DHTPing", "DHTPong", "FindNode", "Neighbors"} {
+		n.Messages.Handle(name, n.handleDHTMessage)
+	}
+}
+
+/*
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to pass the message to the handler of its type
+ * @description: A message type unknown to the node is dropped, the sender is only penalized if it does not speak
+ * @description: a newer protocol version, where the type may exist.
+ * @param: instance of message
+ */
+
+func (n *Node) handleMessage(msg *Message) {
+	err := n.Messages.Dispatch(msg, n.peerProtocol(msg.From))
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrMalformedMessage):
+		log.Printf("Error decoding %s message from %s: %v\n", msg.Type, msg.From, err)
+		n.Misbehaving(msg.From, penaltyMalformedMessage, "malformed "+msg.Type+" message")
+	case errors.Is(err, ErrUnknownMessageType) && n.peerAnnouncedVersion(msg.From) > ProtocolVersion:
+		log.Printf("Ignoring %s message from %s: %v\n", msg.Type, msg.From, err)
+	default:
+		n.Misbehaving(msg.From, penaltyUnknownMessage, "unexpected "+msg.Type+" message")
+	}
+}
+
+/*
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the broadcast the new transaction to the node
+ * @param: address of the node and instance of message
+ * @return: instance of broadcast, Wait reports which peers received the announcement
+ */
+
+func (n *Node) BroadcastNewTransaction(transaction string) *Broadcast {
+	// Peers are only told the hash, they request the transaction if they do not have it
+	item := InvItem{Type: InvTx, Hash: MidLevelBlockchain.ParseTransaction(transaction).Hash()}
+	n.markSeen(item)
+	return n.relayInventory(item, "")
+}
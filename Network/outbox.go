@@ -0,0 +1,457 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const outboxSize = 256                        // Messages waiting for a peer address before new ones are refused
+const maxSendAttempts = 5                     // Attempts to deliver a message before it is given up
+const sendRetryBase = 250 * time.Millisecond  // Wait before the first retry, doubled after each attempt
+const sendRetryMax = 4 * time.Second          // Longest wait between two attempts
+const sendRetryWindow = 15 * time.Second      // No attempt is started this long after the first one, a dead peer holds its queue no longer
+const DefaultBroadcastWait = 15 * time.Second // How long the CLI waits for the deliveries of a broadcast
+const receiptWait = 2 * time.Second           // How long a block broadcast waits for the replies of the peers once it is sent
+
+var (
+	ErrOutboxFull      = errors.New("send queue of the peer is full")
+	ErrPeerClosed      = errors.New("peer closed before the message was written")
+	ErrDeliveryPending = errors.New("delivery still pending")
+	ErrPeerUnreachable = errors.New("peer could not be connected")
+)
+
+// Errors which another attempt cannot fix, the message is given up at once
+var permanentSendErrors = []error{
+	ErrNodeStopped, ErrPeerBanned, ErrSelfConnection, ErrChainMismatch, ErrIncompatibleVersion, ErrAddressMismatch,
+	ErrUntrustedPeer, ErrKeyMismatch, ErrInvalidAddress, ErrUnknownMessageType, ErrMessageNotSupported,
+	ErrFrameTooLarge, ErrUnsupportedType,
+}
+
+// Delivery is the outcome of sending a message to a peer. A message is sent once it was written to the connection,
+// the peer does not acknowledge it, so a peer which closes right after may not have handled it. For the blocks mined
+// by the node, a peer which asks for the block or its missing transactions, or announces it back, is known to have
+// received the announcement; a peer which rebuilt the block from its mempool or already had it does not reply.
+type Delivery struct {
+	Peer     string // Address of the peer
+	Type     string // Message type which was sent
+	Attempts int    // Connections and writes tried, zero if the message was given up with the one queued before it
+	Err      error  // Nil once the message was written to the peer
+	Received bool   // The peer replied to the announcement of the block, only tracked for the blocks mined by the node
+}
+
+// outbox queues the messages of a peer address, a single worker sends them in order while it is not empty.
+type outbox struct {
+	queue chan *outboundMessage
+}
+
+// outboundMessage is a message waiting in an outbox, its delivery is reported on result.
+type outboundMessage struct {
+	msg    *Message
+	result chan Delivery
+}
+
+// Broadcast tracks the deliveries of a message relayed to several peers.
+type Broadcast struct {
+	mu         sync.Mutex
+	pending    []<-chan Delivery
+	deliveries []Delivery // ErrDeliveryPending until the delivery is received
+	done       []bool
+
+	receiptMu sync.Mutex
+	receipts  map[string]bool // Peers which replied to the announcement of the block, nil if the replies are not tracked
+	receipt   chan struct{}   // Signalled when a peer replies
+	started   time.Time
+}
+
+// BroadcastResult lists the delivery of a broadcast to each peer.
+type BroadcastResult struct {
+	Deliveries []Delivery // Sorted by peer address
+	Tracked    bool       // The replies of the peers were tracked, see Delivery.Received
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the message was written to the peer
+ * @return: bool
+ **/
+
+func (d Delivery) Sent() bool {
+	return d.Err == nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to queue the message for the peer address and send it in the background
+ * @description: The peer is connected to if needed. A failed connection or write is retried with exponential backoff,
+ * @description: up to maxSendAttempts times and for sendRetryWindow at most, unless the error is permanent. Messages to the
+ * @description: same peer are sent in order; when the peer cannot be connected, the messages queued behind are given up too.
+ * @param: address of the peer, instance of message
+ * @return: channel which receives the delivery once the message was written or given up
+ **/
+
+func (n *Node) SendReliable(addr string, msg *Message) <-chan Delivery {
+	result := make(chan Delivery, 1)
+	item := &outboundMessage{msg: msg, result: result}
+
+	n.outboxMu.Lock()
+	defer n.outboxMu.Unlock()
+	box, ok := n.outboxes[addr]
+	if !ok {
+		box = &outbox{queue: make(chan *outboundMessage, outboxSize)}
+		if !n.spawn(func() { n.runOutbox(addr, box) }) {
+			result <- Delivery{Peer: addr, Type: msg.Type, Err: ErrNodeStopped}
+			return result
+		}
+		n.outboxes[addr] = box
+	}
+	select {
+	case box.queue <- item:
+	default:
+		log.Printf("Send queue of %s is full, dropping %s message\n", addr, msg.Type)
+		result <- Delivery{Peer: addr, Type: msg.Type, Err: ErrOutboxFull}
+	}
+	return result
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is the worker of the outbox, it sends the queued messages until the outbox is empty
+ * @param: address of the peer, instance of outbox
+ **/
+
+func (n *Node) runOutbox(addr string, box *outbox) {
+	for {
+		select {
+		case item := <-box.queue:
+			delivery := n.deliver(addr, item.msg)
+			if delivery.Err != nil && !errors.Is(delivery.Err, ErrNodeStopped) {
+				log.Printf("Giving up %s message to %s after %d attempt(s): %v\n", item.msg.Type, addr, delivery.Attempts, delivery.Err)
+			}
+			item.result <- delivery
+			if errors.Is(delivery.Err, ErrPeerUnreachable) {
+				box.giveUp(addr, delivery.Err)
+			}
+		default:
+			n.outboxMu.Lock()
+			if len(box.queue) == 0 {
+				delete(n.outboxes, addr)
+				n.outboxMu.Unlock()
+				return
+			}
+			n.outboxMu.Unlock()
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to give up the messages which are queued in the outbox, they would wait for a
+ * @description: peer which could not be connected
+ * @param: address of the peer, error of the message given up before them
+ **/
+
+func (box *outbox) giveUp(addr string, err error) {
+	for {
+		select {
+		case item := <-box.queue:
+			item.result <- Delivery{Peer: addr, Type: item.msg.Type, Err: err}
+		default:
+			return
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the message to the peer, retrying with exponential backoff
+ * @param: address of the peer, instance of message
+ * @return: instance of delivery
+ **/
+
+func (n *Node) deliver(addr string, msg *Message) Delivery {
+	delivery := Delivery{Peer: addr, Type: msg.Type}
+	ctx := n.context()
+	wait := sendRetryBase
+	start := time.Now()
+	for {
+		delivery.Attempts++
+		delivery.Err = n.sendOnce(addr, msg)
+		if delivery.Err == nil || delivery.Attempts >= maxSendAttempts || isPermanentSendError(delivery.Err) {
+			return delivery
+		}
+		if time.Since(start)+wait > sendRetryWindow {
+			return delivery
+		}
+
+		select {
+		case <-ctx.Done():
+			delivery.Err = ErrNodeStopped
+			return delivery
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, sendRetryMax)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to connect to the peer if needed and wait until the message is written to it
+ * @param: address of the peer, instance of message
+ * @return: error if the peer could not be connected or the message was not written
+ **/
+
+func (n *Node) sendOnce(addr string, msg *Message) error {
+	peer, err := n.connectPeer(addr)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPeerUnreachable, err)
+	}
+	written := make(chan error, 1)
+	if err := peer.enqueue(msg, written); err != nil {
+		return err
+	}
+	select {
+	case err := <-written:
+		return err
+	case <-peer.done:
+		select {
+		case err := <-written:
+			return err
+		default:
+			return ErrPeerClosed
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if another attempt to send the message cannot succeed
+ * @param: error of the attempt
+ * @return: bool
+ **/
+
+func isPermanentSendError(err error) bool {
+	for _, permanent := range permanentSendErrors {
+		if errors.Is(err, permanent) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the message to a peer as part of the broadcast
+ * @param: address of the peer, instance of message
+ **/
+
+func (b *Broadcast) send(n *Node, addr string, msg *Message) {
+	delivery := n.SendReliable(addr, msg)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, delivery)
+	b.deliveries = append(b.deliveries, Delivery{Peer: addr, Type: msg.Type, Err: ErrDeliveryPending})
+	b.done = append(b.done, false)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to wait for the deliveries of the broadcast to every peer
+ * @description: The deliveries which are not over when the context is done are reported with ErrDeliveryPending. When
+ * @description: the replies are tracked, the peers the block was sent to are then given receiptWait to reply.
+ * @param: context bounding the wait
+ * @return: instance of broadcast result
+ **/
+
+func (b *Broadcast) Wait(ctx context.Context) *BroadcastResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, pending := range b.pending {
+		if b.done[i] {
+			continue
+		}
+		select {
+		case b.deliveries[i] = <-pending:
+			b.done[i] = true
+		case <-ctx.Done():
+			select {
+			case b.deliveries[i] = <-pending:
+				b.done[i] = true
+			default:
+			}
+		}
+	}
+	result := &BroadcastResult{Deliveries: append([]Delivery(nil), b.deliveries...)}
+	if b.tracked() {
+		b.waitReceipts(ctx, result.Deliveries)
+		result.Tracked = true
+	}
+	sort.SliceStable(result.Deliveries, func(i, j int) bool { return result.Deliveries[i].Peer < result.Deliveries[j].Peer })
+	return result
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the replies of the peers to the broadcast are tracked
+ * @return: bool
+ **/
+
+func (b *Broadcast) tracked() bool {
+	b.receiptMu.Lock()
+	defer b.receiptMu.Unlock()
+	return b.receipts != nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to wait until every peer the block was sent to replied, for receiptWait at most,
+ * @description: and mark the deliveries of the peers which replied
+ * @param: context bounding the wait, slice of deliveries
+ **/
+
+func (b *Broadcast) waitReceipts(ctx context.Context, deliveries []Delivery) {
+	timer := time.NewTimer(receiptWait)
+	defer timer.Stop()
+	for {
+		b.receiptMu.Lock()
+		waiting := 0
+		for i := range deliveries {
+			deliveries[i].Received = b.receipts[deliveries[i].Peer]
+			if deliveries[i].Sent() && !deliveries[i].Received {
+				waiting++
+			}
+		}
+		b.receiptMu.Unlock()
+		if waiting == 0 {
+			return
+		}
+
+		select {
+		case <-b.receipt:
+		case <-timer.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to track the replies of the peers to the announcement of the block mined by the
+ * @description: node, the broadcasts older than DefaultBroadcastWait stop being tracked
+ * @param: hash of the block, instance of broadcast
+ **/
+
+func (n *Node) trackReceipts(hash string, b *Broadcast) {
+	b.receiptMu.Lock()
+	b.receipts = make(map[string]bool)
+	b.receipt = make(chan struct{}, 1)
+	b.started = time.Now()
+	b.receiptMu.Unlock()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for old, tracked := range n.receipts {
+		if time.Since(tracked.started) >= DefaultBroadcastWait {
+			delete(n.receipts, old)
+		}
+	}
+	n.receipts[hash] = b
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to record that the peer replied to the announcement of the block: it asked for
+ * @description: the block or its transactions, or announced the block itself
+ * @param: address of the peer, hash of the block
+ **/
+
+func (n *Node) confirmReceipt(addr string, hash string) {
+	n.mu.Lock()
+	b := n.receipts[hash]
+	n.mu.Unlock()
+	if b == nil {
+		return
+	}
+
+	b.receiptMu.Lock()
+	defer b.receiptMu.Unlock()
+	if !b.receipts[addr] {
+		b.receipts[addr] = true
+		select {
+		case b.receipt <- struct{}{}:
+		default:
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the peers the broadcast was written to
+ * @return: slice of peer addresses
+ **/
+
+func (r *BroadcastResult) Sent() []string {
+	var peers []string
+	for _, delivery := range r.Deliveries {
+		if delivery.Sent() {
+			peers = append(peers, delivery.Peer)
+		}
+	}
+	return peers
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the peers which replied to the announcement of the block
+ * @return: slice of peer addresses
+ **/
+
+func (r *BroadcastResult) Received() []string {
+	var peers []string
+	for _, delivery := range r.Deliveries {
+		if delivery.Received {
+			peers = append(peers, delivery.Peer)
+		}
+	}
+	return peers
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the readable summary of the broadcast, one line per peer
+ * @description: A message counts as sent once it is written to the connection; only the replies of the peers to the
+ * @description: announcement of a block show that they received it.
+ * @return: string
+ **/
+
+func (r *BroadcastResult) String() string {
+	if len(r.Deliveries) == 0 {
+		return "No peer to broadcast to"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sent to %d/%d peer(s) (written to the connection, not acknowledged)", len(r.Sent()), len(r.Deliveries))
+	if r.Tracked {
+		fmt.Fprintf(&b, ", %d replied to the announcement (a peer which rebuilt the block or had it does not reply)", len(r.Received()))
+	}
+	for _, delivery := range r.Deliveries {
+		switch {
+		case delivery.Received:
+			fmt.Fprintf(&b, "\n  %s: %s sent after %d attempt(s), the peer replied", delivery.Peer, delivery.Type, delivery.Attempts)
+		case delivery.Sent():
+			fmt.Fprintf(&b, "\n  %s: %s sent after %d attempt(s)", delivery.Peer, delivery.Type, delivery.Attempts)
+		case errors.Is(delivery.Err, ErrDeliveryPending):
+			fmt.Fprintf(&b, "\n  %s: still pending", delivery.Peer)
+		default:
+			fmt.Fprintf(&b, "\n  %s: %s failed after %d attempt(s): %v", delivery.Peer, delivery.Type, delivery.Attempts, delivery.Err)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that the messages queued for a peer which cannot be connected are given up with the
+ * @description: message before them, instead of each one retrying the connection in turn
+ **/
+
+func TestUnreachablePeerGivesUpQueue(t *testing.T) {
+	nodes, stop, err := startSimulationNodes(1, 30800, newTestMemoryNetwork(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	node := nodes[0]
+
+	const dead = "127.0.0.1:30899" // Nobody listens on it
+	var results []<-chan Delivery
+	for i := 0; i < 3; i++ {
+		results = append(results, node.SendReliable(dead, &Message{Type: "GetAddr", From: node.Address}))
+	}
+	for i, result := range results {
+		delivery := <-result
+		if !errors.Is(delivery.Err, ErrPeerUnreachable) {
+			t.Fatalf("message %d ended with %v, want %v", i, delivery.Err, ErrPeerUnreachable)
+		}
+		if i == 0 && delivery.Attempts != maxSendAttempts {
+			t.Errorf("first message was tried %d times, want %d", delivery.Attempts, maxSendAttempts)
+		}
+		if i > 0 && delivery.Attempts != 0 {
+			t.Errorf("message %d queued behind was tried %d times, want none", i, delivery.Attempts)
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that the broadcast of a mined block tells the peers which replied to the
+ * @description: announcement apart from those it was only written to
+ **/
+
+func TestBlockBroadcastReceipts(t *testing.T) {
+	nodes, stop, err := startSimulationNodes(2, 31100, newTestMemoryNetwork(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if err := nodes[0].AddPeer(nodes[1].Address); err != nil {
+		t.Fatal(err)
+	}
+	waitForNodes(nodes, func(node *Node) bool { return len(node.Peers()) == 1 })
+
+	// The peer misses the transaction of the block, it asks for it
+	block := nodes[0].Blockchain.ForceMineBlock([]string{"transaction the peer misses"}, nodes[0].Blockchain.LatestHash())
+	if block == nil {
+		t.Fatal("mining the block failed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultBroadcastWait)
+	defer cancel()
+	result := nodes[0].BroadcastNewBlock(block).Wait(ctx)
+	if !result.Tracked || len(result.Received()) != 1 || result.Received()[0] != nodes[1].Address {
+		t.Fatalf("broadcast of the block reports %v", result)
+	}
+
+	// The peer rebuilds the block from its mempool, it was sent the block but does not reply
+	tx := MidLevelBlockchain.NewTransaction("transaction the peer has")
+	if _, err := nodes[1].Mempool.AddTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	block = nodes[0].Blockchain.ForceMineBlock([]string{tx.Encode()}, nodes[0].Blockchain.LatestHash())
+	if block == nil {
+		t.Fatal("mining the block failed")
+	}
+	result = nodes[0].BroadcastNewBlock(block).Wait(ctx)
+	if len(result.Sent()) != 1 || len(result.Received()) != 0 {
+		t.Fatalf("broadcast of the block reports %v", result)
+	}
+	if agreed, _ := waitForTip(nodes, block.CurrentHash, testSyncTimeout); !agreed {
+		t.Fatal("the peer did not rebuild the block")
+	}
+}
@@ -0,0 +1,377 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const peerSendQueueSize = 256 // Messages waiting on a stream of a peer before new ones are dropped
+
+// queuedMessage is a message waiting for the write loop of a peer.
+type queuedMessage struct {
+	msg     *Message
+	written chan error // Receives the result of the write, nil if nobody waits for it
+}
+
+// Peer is a long-lived connection to another node which completed the version handshake.
+// The connection carries several streams. Each peer has its own read loop, which reassembles the incoming messages,
+// a handle loop per stream, which handles the messages of the stream in order, and a write loop.
+// A peer older than streamsVersion is sent whole frames, and its read loop handles the messages itself.
+type Peer struct {
+	node     *Node
+	conn     net.Conn
+	Inbound  bool        // The peer connected to us
+	Version  VersionInfo // Version announced by the peer during the handshake
+	ID       NodeID      // Node ID of the identity key the peer proved during the handshake, it identifies the peer
+	Protocol int         // Protocol version spoken with the peer, the older of both sides
+	Codec    Codec       // Codec of the messages after the handshake, negotiated from the versions, JSON before codecsVersion
+
+	messages  *tokenBucket            // Message rate limit of the reads
+	bytes     *tokenBucket            // Byte rate limit of the reads
+	out       [numStreams]*sendStream // Sending side of the streams, by priority
+	in        [numStreams]*recvStream // Receiving side of the streams
+	wake      chan struct{}           // Wakes up the write loop when a message is queued or a window is granted
+	done      chan struct{}
+	closeOnce sync.Once
+	drain     chan struct{} // Closed when the peer should write its queued messages and close
+	drainOnce sync.Once
+	pong      chan struct{} // Signalled when the pong of the outstanding ping arrives
+	liveMu    sync.Mutex
+	live      peerLiveness
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the peer for the connection which completed the handshake
+ * @param: instance of node, connection, inbound bool and the version of the peer
+ * @return: instance of peer
+ **/
+
+func newPeer(n *Node, conn net.Conn, inbound bool, version VersionInfo) *Peer {
+	protocol := min(version.ProtocolVersion, n.protocol)
+	var codec Codec = JSONCodec{}
+	switch {
+	case protocol < codecsVersion:
+	case inbound:
+		codec = negotiateCodec(version.Codecs, n.Codecs)
+	default:
+		codec = negotiateCodec(n.Codecs, version.Codecs)
+	}
+	p := &Peer{
+		node:     n,
+		conn:     conn,
+		Inbound:  inbound,
+		Version:  version,
+		ID:       NodeIDFromKey(version.PublicKey),
+		Protocol: protocol,
+		Codec:    codec,
+		messages: newTokenBucket(messageRate, messageBurst),
+		bytes:    newTokenBucket(byteRate, byteBurst),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		drain:    make(chan struct{}),
+		pong:     make(chan struct{}, 1),
+		live:     peerLiveness{connected: time.Now(), lastSeen: time.Now()},
+	}
+	p.initStreams()
+	return p
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the listening address announced by the peer, it is used to reach the peer
+ * @return: address string
+ **/
+
+func (p *Peer) Address() string {
+	return p.Version.Address
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the IP address the connection of the peer comes from
+ * @return: IP address string
+ **/
+
+func (p *Peer) RemoteIP() string {
+	return remoteIP(p.conn)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to queue the message for the write loop of the peer
+ * @param: instance of message
+ * @return: false if the peer is closed, its queue is full or it does not speak the protocol version of the message type
+ **/
+
+func (p *Peer) Send(msg *Message) bool {
+	return p.enqueue(msg, nil) == nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to queue the message on its stream, the result of its write is sent on written
+ * @param: instance of message, channel with room for the result of the write (nil if it is not needed)
+ * @return: error if the peer is closed, its queue is full or it does not speak the protocol version of the message type
+ **/
+
+func (p *Peer) enqueue(msg *Message, written chan error) error {
+	if p.Closed() {
+		return ErrPeerClosed
+	}
+	if !p.Supports(msg.Type) {
+		return fmt.Errorf("%w: %s", ErrMessageNotSupported, msg.Type)
+	}
+
+	select {
+	case p.out[p.node.Messages.streamOf(msg.Type)].queue <- &queuedMessage{msg: msg, written: written}:
+		p.signal()
+		return nil
+	default:
+		log.Printf("Send queue of peer %s is full, dropping %s message\n", p.Address(), msg.Type)
+		return ErrOutboxFull
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the message type can be sent to the peer
+ * @param: name of the message type
+ * @return: false if the type is not registered or newer than the protocol version spoken with the peer
+ **/
+
+func (p *Peer) Supports(msgType string) bool {
+	t, ok := p.node.Messages.Lookup(msgType)
+	return ok && t.Version <= p.Protocol
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to close the connection of the peer, it is safe to call more than once
+ **/
+
+func (p *Peer) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.conn.Close()
+	})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to close the peer once the messages which are already queued are written,
+ * @description: it is used when the node stops. It is safe to call more than once.
+ **/
+
+func (p *Peer) Drain() {
+	p.drainOnce.Do(func() {
+		close(p.drain)
+	})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the peer is closed
+ * @return: bool
+ **/
+
+func (p *Peer) Closed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to start the read, write, handle and ping loops of the peer
+ **/
+
+func (p *Peer) start() {
+	loops := []func(){p.writeLoop, p.readLoop, p.pingLoop}
+	for _, in := range p.in {
+		in := in
+		if p.multiplexed() {
+			loops = append(loops, func() { p.handleLoop(in) })
+		}
+	}
+	for _, loop := range loops {
+		if !p.node.spawn(loop) {
+			p.Close()
+			p.node.removePeer(p)
+			return
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the connection of the peer is multiplexed into streams
+ * @return: false if the peer is older than streamsVersion and is sent whole frames
+ **/
+
+func (p *Peer) multiplexed() bool {
+	return p.Protocol >= streamsVersion
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is the read loop of the peer, it reads chunks, or the frames of an older peer, until the
+ * @description: connection is closed. Reads are slowed down past the byte rate limit, messages past the message rate limit
+ * @description: are dropped and penalized, and a peer which sends nothing for idleTimeout is disconnected.
+ **/
+
+func (p *Peer) readLoop() {
+	defer p.node.removePeer(p)
+	defer p.Close()
+
+	for {
+		p.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		var err error
+		if p.multiplexed() {
+			var c chunk
+			if c, err = readChunk(p.conn); err == nil {
+				p.touch()
+				p.throttle(chunkHeaderSize + len(c.data))
+				err = p.receiveChunk(c)
+			}
+		} else {
+			err = p.readFrame()
+		}
+		if err != nil {
+			if err != io.EOF && !p.Closed() {
+				log.Printf("Error reading from peer %s: %v\n", p.Address(), err)
+			}
+			for _, invalid := range invalidFrameErrors {
+				if errors.Is(err, invalid) {
+					p.node.Misbehaving(p.Address(), penaltyInvalidFrame, err.Error())
+					break
+				}
+			}
+			return
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read the next frame of a peer older than streamsVersion and handle its message
+ * @return: error if the frame could not be read, the peer is then disconnected
+ **/
+
+func (p *Peer) readFrame() error {
+	msg, err := p.node.Messages.ReadFrame(p.conn, p.Codec)
+	if err != nil {
+		return err
+	}
+	p.touch()
+	p.throttle(frameHeaderSize + len(msg.Data))
+	msg.From = p.Address() // The handshake tells who the sender is
+	if !p.messages.Allow(1) {
+		p.node.Misbehaving(p.Address(), penaltyRateLimited, "message rate limit exceeded")
+		return nil
+	}
+	p.node.handleMessage(msg)
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to write the next queued message to a peer older than streamsVersion, as a whole
+ * @description: frame. The streams are still taken by priority, there is no window to wait for.
+ * @return: false if there was nothing to write, error if the write failed
+ **/
+
+func (p *Peer) writeFrame() (bool, error) {
+	for _, out := range p.out {
+		if !p.nextMessage(out) {
+			continue
+		}
+		p.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := p.conn.Write(out.frame); err != nil {
+			if !p.Closed() {
+				log.Printf("Error sending %s message to peer %s: %v\n", out.current.msg.Type, p.Address(), err)
+			}
+			out.finish(err)
+			return true, err
+		}
+		p.node.traffic.record(out.current.msg.Type, len(out.frame))
+		out.finish(nil)
+		return true, nil
+	}
+	return false, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to slow down the read loop once the peer is past its byte rate limit
+ * @param: bytes read
+ **/
+
+func (p *Peer) throttle(n int) {
+	if wait := p.bytes.Reserve(float64(n)); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is the write loop of the peer, it writes the chunks of the streams, or whole frames to an
+ * @description: older peer, until the peer is closed. When the peer is drained the messages still queued are written before
+ * @description: the peer is closed.
+ **/
+
+func (p *Peer) writeLoop() {
+	write := p.writeNext
+	if !p.multiplexed() {
+		write = p.writeFrame
+	}
+	drain := p.drain
+	for !p.Closed() {
+		wrote, err := write()
+		if err != nil {
+			p.Close()
+			return
+		}
+		if wrote {
+			continue
+		}
+		if drain == nil && p.idle() {
+			p.Close()
+			return
+		}
+		select {
+		case <-p.done:
+			return
+		case <-drain:
+			drain = nil // Closed for good, the loop now ends once the queues are empty
+		case <-p.wake:
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to move the messages which are still queued to another peer,
+ * @description: it is used when a duplicate connection to the same node is closed. The messages whose sender waits
+ * @description: for their write are left out, the sender sees the peer close and sends them again.
+ * @param: the peer which takes over the queued messages
+ **/
+
+func (p *Peer) transferQueue(to *Peer) {
+	for _, out := range p.out {
+		for len(out.queue) > 0 {
+			item := <-out.queue
+			if item.written == nil {
+				to.Send(item.msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that a node still speaks to peers on the older protocol versions it accepts: the peer
+ * @description: is sent whole frames, in JSON before the codec negotiation, and only pinged from the version which
+ * @description: introduced the pings, while the blocks and transactions flow both ways
+ **/
+
+func TestOlderProtocolPeer(t *testing.T) {
+	for i, version := range []int{MinProtocolVersion, streamsVersion - 1} {
+		t.Run(fmt.Sprintf("version %d", version), func(t *testing.T) {
+			nodes, stop, err := startSimulationNodes(2, 30500+100*i, newTestMemoryNetwork(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stop()
+			current, older := nodes[0], nodes[1]
+			older.protocol = version
+			if err := older.AddPeer(current.Address); err != nil {
+				t.Fatal(err)
+			}
+
+			waitForNodes(nodes, func(node *Node) bool { return len(node.Peers()) == 1 })
+			peers := current.Peers()
+			if len(peers) != 1 {
+				t.Fatalf("node has %d peers, want 1", len(peers))
+			}
+			peer := peers[0]
+			if peer.Protocol != version || peer.multiplexed() {
+				t.Fatalf("peer speaks version %d, multiplexed %v", peer.Protocol, peer.multiplexed())
+			}
+			if version < codecsVersion && peer.Codec.Name() != "json" {
+				t.Fatalf("peer of version %d speaks %s, want json", version, peer.Codec.Name())
+			}
+
+			block := current.Blockchain.ForceMineBlock([]string{"block for an older peer"}, current.Blockchain.LatestHash())
+			if block == nil {
+				t.Fatal("mining the block failed")
+			}
+			current.BroadcastNewBlock(block)
+			if agreed, _ := waitForTip(nodes, block.CurrentHash, testSyncTimeout); !agreed {
+				t.Fatalf("the older node did not receive the block, its height is %d", older.Blockchain.Height())
+			}
+
+			tx := MidLevelBlockchain.NewTransaction("transaction of an older peer")
+			if _, err := older.Mempool.AddTransaction(tx); err != nil {
+				t.Fatal(err)
+			}
+			older.BroadcastNewTransaction(tx.Encode())
+			if reached, _ := waitForNodes(nodes, func(node *Node) bool { return node.Mempool.Count() == 1 }); reached != len(nodes) {
+				t.Fatal("the transaction of the older node did not reach the node")
+			}
+
+			if version >= pingVersion(current) {
+				time.Sleep(3 * simulationPingInterval)
+				if peer.Metrics().Pongs == 0 {
+					t.Error("the peer did not answer the pings")
+				}
+			} else if peer.Metrics().Pings != 0 {
+				t.Errorf("the peer was sent %d pings, its version has none", peer.Metrics().Pings)
+			}
+		})
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that a peer older than MinProtocolVersion is refused
+ **/
+
+func TestTooOldProtocolPeerRefused(t *testing.T) {
+	nodes, stop, err := startSimulationNodes(2, 30700, newTestMemoryNetwork(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	nodes[1].protocol = MinProtocolVersion - 1
+	if err := nodes[0].AddPeer(nodes[1].Address); !errors.Is(err, ErrIncompatibleVersion) {
+		t.Fatalf("connecting to the older node returned %v, want %v", err, ErrIncompatibleVersion)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the protocol version which introduced the pings
+ * @param: instance of node
+ * @return: protocol version
+ **/
+
+func pingVersion(n *Node) int {
+	t, _ := n.Messages.Lookup("Ping")
+	return t.Version
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that a banned loopback peer, whose IP address every local node shares, is refused
+ * @description: by the address it announced once it comes back with a new identity key
+ **/
+
+func TestLoopbackPeerBannedByAddress(t *testing.T) {
+	nodes, stop, err := startSimulationNodes(2, 31200, newTestMemoryNetwork(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	node, banned := nodes[0], nodes[1]
+	if err := banned.AddPeer(node.Address); err != nil {
+		t.Fatal(err)
+	}
+	waitForNodes(nodes, func(node *Node) bool { return len(node.Peers()) == 1 })
+	if err := node.BanPeer(banned.ID().String(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if node.BanList.IsBanned("127.0.0.1") {
+		t.Error("the loopback IP address shared by the local nodes was banned")
+	}
+
+	banned.Stop()
+	banned.SetIdentity(GenerateIdentity())
+	if err := banned.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	banned.AddPeer(node.Address) // Refused by the node once the handshake tells who it is
+	time.Sleep(100 * time.Millisecond)
+	if len(node.Peers()) != 0 {
+		t.Fatal("the banned peer connected again with a new key")
+	}
+	if err := node.AddPeer(banned.Address); !errors.Is(err, ErrPeerBanned) {
+		t.Fatalf("dialing the banned peer returned %v, want %v", err, ErrPeerBanned)
+	}
+}
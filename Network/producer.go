@@ -0,0 +1,140 @@
+package network
+
+import (
+	"context"
+	"log"
+	"time"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+const DefaultMaxBlockWait = 30 * time.Second        // Mine whatever is pending after this long
+const producerPollInterval = 500 * time.Millisecond // How often the producer checks the mempool
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to start the automatic block producer in the background
+ * @description: A block is mined as soon as the mempool holds the minimum number of transactions per block,
+ * @description: or once the oldest pending transaction has waited for maxWait. The block is then broadcast.
+ * @param: maxWait time.Duration, DefaultMaxBlockWait is used if it is not positive
+ * @return: false if the producer is already running or the node is not running
+ **/
+
+func (n *Node) StartBlockProducer(maxWait time.Duration) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.producerStop != nil {
+		return false
+	}
+	if maxWait <= 0 {
+		maxWait = DefaultMaxBlockWait
+	}
+
+	stop := make(chan struct{})
+	if !n.spawn(func() { n.runBlockProducer(maxWait, stop) }) {
+		return false
+	}
+	n.producerStop = stop
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to stop the automatic block producer
+ * @return: false if the producer is not running
+ **/
+
+func (n *Node) StopBlockProducer() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.producerStop == nil {
+		return false
+	}
+	close(n.producerStop)
+	n.producerStop = nil
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the automatic block producer is running
+ * @return: bool
+ **/
+
+func (n *Node) BlockProducerRunning() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.producerStop != nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is the loop of the block producer, it polls the mempool until it is stopped
+ * @description: The block being mined is given up when the producer or the node is stopped.
+ * @param: maxWait time.Duration, stop channel
+ **/
+
+func (n *Node) runBlockProducer(maxWait time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(producerPollInterval)
+	defer ticker.Stop()
+	ctx, cancel := context.WithCancel(n.context())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	log.Println("Block producer started")
+	for {
+		select {
+		case <-stop:
+			log.Println("Block producer stopped")
+			return
+		case <-ticker.C:
+			pending := n.Mempool.Count()
+			if pending == 0 {
+				continue
+			}
+
+			if pending >= n.Blockchain.GetNumberOfTransactionsPerBlock() {
+				n.produceBlock(ctx, false)
+			} else if n.Mempool.OldestAge() >= maxWait {
+				n.produceBlock(ctx, true)
+			}
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to mine the pending transactions of the mempool into a block and broadcast it
+ * @param: context of the mining, force bool, if true the block is mined with fewer transactions than the minimum
+ * @return: instance of block, nil if no block was mined or the context was done first
+ **/
+
+func (n *Node) produceBlock(ctx context.Context, force bool) *MidLevelBlockchain.Block {
+	var transactions []string
+	for _, tx := range n.Mempool.PendingTransactions() {
+		transactions = append(transactions, tx.Encode())
+	}
+
+	var block *MidLevelBlockchain.Block
+	if force {
+		block = n.Blockchain.ForceMineBlockContext(ctx, transactions, n.Blockchain.LatestHash())
+	} else {
+		block = n.Blockchain.MineBlockContext(ctx, transactions, n.Blockchain.LatestHash())
+	}
+	if block == nil {
+		return nil
+	}
+
+	n.Mempool.RemoveBlockTransactions(block)
+	log.Printf("Block producer mined a block with %d transactions. Broadcasting...\n", len(block.Transactions))
+	n.BroadcastNewBlock(block)
+	return block
+}
@@ -0,0 +1,157 @@
+package network
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const DefaultMaxInbound = 64     // Inbound connections accepted at the same time, handshakes included
+const DefaultMaxInboundPerIP = 4 // Inbound connections accepted at the same time from a single IP address, loopback excepted
+
+const messageRate = 500               // Messages per second read from a peer
+const messageBurst = 1000             // Messages a peer can send at once before it is rate limited
+const byteRate = 4 << 20              // Bytes per second read from a peer
+const byteBurst = 16 << 20            // Bytes a peer can send at once before its reads are slowed down
+const idleTimeout = 10 * time.Minute  // A peer which sends nothing for this long is disconnected
+const writeTimeout = 30 * time.Second // A frame which cannot be written in this time closes the connection
+
+// tokenBucket refills rate tokens per second up to burst tokens, each message or byte read takes a token.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the token bucket, it starts full
+ * @param: rate of the tokens per second, burst int (capacity of the bucket)
+ * @return: instance of token bucket
+ **/
+
+func newTokenBucket(rate float64, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add the tokens refilled since the last call, it must be called with the lock held
+ **/
+
+func (b *tokenBucket) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to take the tokens if the bucket has enough of them
+ * @param: number of tokens
+ * @return: false if the bucket does not have enough tokens, none are taken then
+ **/
+
+func (b *tokenBucket) Allow(n float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to take the tokens, the bucket goes into debt if it does not have enough of them
+ * @param: number of tokens
+ * @return: how long to wait until the debt is paid back
+ **/
+
+func (b *tokenBucket) Reserve(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the IP address of the remote side of the connection
+ * @param: connection
+ * @return: IP address string
+ **/
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the IP address is a loopback address, i.e. the connection comes from this machine
+ * @param: IP address string
+ * @return: bool
+ **/
+
+func isLoopback(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.IsLoopback()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to reserve an inbound slot for the accepted connection
+ * @description: The connection is refused when MaxInbound connections are open, or MaxInboundPerIP from its IP address.
+ * @description: Loopback connections are not limited per IP, all the nodes of a local cluster share that address.
+ * @param: connection
+ * @return: false if the connection must be refused
+ **/
+
+func (n *Node) acquireInbound(conn net.Conn) bool {
+	ip := remoteIP(conn)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.inbound >= n.MaxInbound {
+		log.Printf("Refusing connection from %s: %d inbound connections already open\n", conn.RemoteAddr(), n.inbound)
+		return false
+	}
+	if !isLoopback(ip) && n.inboundByIP[ip] >= n.MaxInboundPerIP {
+		log.Printf("Refusing connection from %s: %d connections already open from %s\n", conn.RemoteAddr(), n.inboundByIP[ip], ip)
+		return false
+	}
+	n.inbound++
+	n.inboundByIP[ip]++
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to free the inbound slot of the connection once it is closed
+ * @param: connection
+ **/
+
+func (n *Node) releaseInbound(conn net.Conn) {
+	ip := remoteIP(conn)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.inbound--
+	if n.inboundByIP[ip]--; n.inboundByIP[ip] <= 0 {
+		delete(n.inboundByIP, ip)
+	}
+}
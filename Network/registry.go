@@ -0,0 +1,259 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MessageCode identifies a message type on the wire, it is sent in the frame header instead of the type name.
+type MessageCode uint16
+
+const FirstApplicationCode MessageCode = 0x8000 // Codes from here on are left to the message types of applications
+const maxMessageNameSize = 32                   // Longest name of a message type
+
+var (
+	ErrInvalidMessageType   = errors.New("message type needs a name and a non-zero code")
+	ErrDuplicateMessageType = errors.New("message type is already registered")
+	ErrUnknownMessageType   = errors.New("message type is not registered")
+	ErrMessageNotSupported  = errors.New("message type is newer than the protocol version of the peer")
+	ErrNoHandler            = errors.New("message type has no handler")
+	ErrMalformedMessage     = errors.New("message payload could not be decoded")
+)
+
+// MessageType describes a message type of the protocol.
+type MessageType struct {
+	Code       MessageCode // Sent in the frame header
+	Name       string      // Value of Message.Type
+	Version    int         // Protocol version which introduced the type, peers on an older version are not sent it
+	MaxPayload uint32      // Largest payload accepted, defaultMaxPayloadSize if zero
+	Stream     StreamID    // Stream of the connection the messages are sent on, StreamControl if not set
+}
+
+// Message types of the protocol, with the version which introduced them and the stream they are sent on.
+var builtinMessageTypes = []MessageType{
+	{Code: 0x0001, Name: "Version", Version: 1},
+	{Code: 0x0002, Name: "Verack", Version: 1},
+	{Code: 0x0003, Name: "Ping", Version: 6, MaxPayload: 1 << 10},
+	{Code: 0x0004, Name: "Pong", Version: 6, MaxPayload: 1 << 10},
+	{Code: 0x0010, Name: "NewBlock", Version: 1, MaxPayload: 8 << 20, Stream: StreamBlocks},
+	{Code: 0x0011, Name: "NewTransaction", Version: 1, MaxPayload: 256 << 10, Stream: StreamTransactions},
+	{Code: 0x0012, Name: "Services", Version: 1, MaxPayload: 4 << 10},
+	{Code: 0x0020, Name: "GetAddr", Version: 1},
+	{Code: 0x0021, Name: "Addr", Version: 1},
+	{Code: 0x0030, Name: "GetBlocks", Version: 2, Stream: StreamSync},
+	{Code: 0x0031, Name: "Block", Version: 2, MaxPayload: 8 << 20, Stream: StreamSync},
+	{Code: 0x0040, Name: "Inv", Version: 2, MaxPayload: 256 << 10, Stream: StreamTransactions},
+	{Code: 0x0041, Name: "GetData", Version: 2, MaxPayload: 256 << 10, Stream: StreamTransactions},
+	{Code: 0x0042, Name: "NotFound", Version: 2, MaxPayload: 256 << 10, Stream: StreamTransactions},
+	{Code: 0x0043, Name: "Tx", Version: 2, MaxPayload: 256 << 10, Stream: StreamTransactions},
+	{Code: 0x0050, Name: "CmpctBlock", Version: 2, MaxPayload: 1 << 20, Stream: StreamBlocks},
+	{Code: 0x0051, Name: "GetBlockTxn", Version: 2, MaxPayload: 256 << 10, Stream: StreamBlocks},
+	{Code: 0x0052, Name: "BlockTxn", Version: 2, MaxPayload: 8 << 20, Stream: StreamBlocks},
+	{Code: 0x0060, Name: "DHTPing", Version: 2},
+	{Code: 0x0061, Name: "DHTPong", Version: 2},
+	{Code: 0x0062, Name: "FindNode", Version: 2},
+	{Code: 0x0063, Name: "Neighbors", Version: 2},
+}
+
+// Registry of the built-in message types, used by the frame functions of the package
+var defaultMessages = NewDefaultMessageRegistry()
+
+// MessageRegistry maps the message types to their codes and handlers.
+// Each node has its own registry, so applications can add their message types and handlers to it.
+type MessageRegistry struct {
+	mu       sync.RWMutex
+	byCode   map[MessageCode]*MessageType
+	byName   map[string]*MessageType
+	handlers map[string]func(msg *Message) error
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create an empty message registry
+ * @return: instance of message registry
+ **/
+
+func NewMessageRegistry() *MessageRegistry {
+	return &MessageRegistry{
+		byCode:   make(map[MessageCode]*MessageType),
+		byName:   make(map[string]*MessageType),
+		handlers: make(map[string]func(msg *Message) error),
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create a message registry holding the built-in message types, without handlers
+ * @return: instance of message registry
+ **/
+
+func NewDefaultMessageRegistry() *MessageRegistry {
+	r := NewMessageRegistry()
+	for _, t := range builtinMessageTypes {
+		if err := r.Register(t); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to add a message type to the registry
+ * @description: Applications should use codes from FirstApplicationCode on. A type without a version is given the current one.
+ * @param: instance of message type
+ * @return: error if the type is invalid or its code or name is already registered
+ **/
+
+func (r *MessageRegistry) Register(t MessageType) error {
+	if t.Code == 0 || t.Name == "" || len(t.Name) > maxMessageNameSize || t.Stream >= numStreams {
+		return fmt.Errorf("%w: %q", ErrInvalidMessageType, t.Name)
+	}
+	if t.Version <= 0 {
+		t.Version = ProtocolVersion
+	}
+	if t.MaxPayload == 0 {
+		t.MaxPayload = defaultMaxPayloadSize
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byCode[t.Code]; ok {
+		return fmt.Errorf("%w: code 0x%04x", ErrDuplicateMessageType, uint16(t.Code))
+	}
+	if _, ok := r.byName[t.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateMessageType, t.Name)
+	}
+	r.byCode[t.Code] = &t
+	r.byName[t.Name] = &t
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to find the message type by its name
+ * @param: name of the message type
+ * @return: instance of message type and false if it is not registered
+ **/
+
+func (r *MessageRegistry) Lookup(name string) (MessageType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.byName[name]
+	if !ok {
+		return MessageType{}, false
+	}
+	return *t, true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to find the message type by its code
+ * @param: code of the message type
+ * @return: instance of message type and false if it is not registered
+ **/
+
+func (r *MessageRegistry) LookupCode(code MessageCode) (MessageType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.byCode[code]
+	if !ok {
+		return MessageType{}, false
+	}
+	return *t, true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the registered message types
+ * @return: slice of message types sorted by code
+ **/
+
+func (r *MessageRegistry) Types() []MessageType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]MessageType, 0, len(r.byCode))
+	for _, t := range r.byCode {
+		types = append(types, *t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Code < types[j].Code })
+	return types
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to set the handler of a message type, the handler decodes the payload itself
+ * @description: A handler set before replaces the previous one.
+ * @param: name of the message type, handler function
+ * @return: error if the message type is not registered
+ **/
+
+func (r *MessageRegistry) Handle(name string, handler func(msg *Message)) error {
+	return r.setHandler(name, func(msg *Message) error {
+		handler(msg)
+		return nil
+	})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to set a typed handler of a message type, the payload is decoded into T before
+ * @description: the handler is called. A payload which cannot be decoded is reported as ErrMalformedMessage.
+ * @param: instance of message registry, name of the message type, handler function
+ * @return: error if the message type is not registered
+ **/
+
+func HandlePayload[T any](r *MessageRegistry, name string, handler func(msg *Message, payload *T)) error {
+	return r.setHandler(name, func(msg *Message) error {
+		payload := new(T)
+		if err := msg.DecodePayload(payload); err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformedMessage, err)
+		}
+		handler(msg, payload)
+		return nil
+	})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to set the handler of a registered message type
+ * @param: name of the message type, handler function
+ * @return: error if the message type is not registered
+ **/
+
+func (r *MessageRegistry) setHandler(name string, handler func(msg *Message) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byName[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownMessageType, name)
+	}
+	r.handlers[name] = handler
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to call the handler of the message type
+ * @param: instance of message, protocol version negotiated with the sender
+ * @return: error if the type is unknown, newer than the protocol version, has no handler or its payload is malformed
+ **/
+
+func (r *MessageRegistry) Dispatch(msg *Message, protocol int) error {
+	r.mu.RLock()
+	t, ok := r.byName[msg.Type]
+	handler := r.handlers[msg.Type]
+	r.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownMessageType, msg.Type)
+	}
+	if t.Version > protocol {
+		return fmt.Errorf("%w: %s needs version %d", ErrMessageNotSupported, msg.Type, t.Version)
+	}
+	if handler == nil {
+		return fmt.Errorf("%w: %s", ErrNoHandler, msg.Type)
+	}
+	return handler(msg)
+}
@@ -0,0 +1,222 @@
+package network
+
+import (
+	"crypto/rand"
+	"sort"
+	"sync"
+)
+
+const bucketSize = 20 // k, the number of contacts kept per bucket and returned by FindNode
+
+// Contact is a node known to the DHT.
+type Contact struct {
+	ID      NodeID
+	Address string // Listening address of the node
+}
+
+// RoutingTable keeps the contacts of the DHT in k-buckets, bucket i holds the contacts whose ID has
+// exactly i leading bits in common with the local ID. Each bucket is ordered from the least to the
+// most recently seen contact.
+type RoutingTable struct {
+	mu      sync.Mutex
+	self    NodeID
+	buckets [idBits][]Contact
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the routing table of the node with the given ID
+ * @param: node ID of the local node
+ * @return: instance of routing table
+ **/
+
+func NewRoutingTable(self NodeID) *RoutingTable {
+	return &RoutingTable{self: self}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to record that the contact was seen
+ * @description: A known contact is moved to the tail of its bucket and a new one is appended if the bucket has room.
+ * @description: If the bucket is full its least recently seen contact is returned, the caller pings it and calls
+ * @description: Replace if it does not answer, so long-lived contacts are preferred over new ones.
+ * @param: instance of contact
+ * @return: the contact to ping and true if the bucket is full
+ **/
+
+func (rt *RoutingTable) Update(contact Contact) (Contact, bool) {
+	if contact.ID == rt.self || contact.Address == "" {
+		return Contact{}, false
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	index := rt.self.CommonPrefixLen(contact.ID)
+	bucket := rt.buckets[index]
+	for i, known := range bucket {
+		if known.ID == contact.ID {
+			bucket = append(bucket[:i], bucket[i+1:]...)
+			rt.buckets[index] = append(bucket, contact)
+			return Contact{}, false
+		}
+	}
+	if len(bucket) < bucketSize {
+		rt.buckets[index] = append(bucket, contact)
+		return Contact{}, false
+	}
+	return bucket[0], true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to replace the stale contact, which did not answer a ping, by the new one
+ * @param: stale contact, new contact
+ **/
+
+func (rt *RoutingTable) Replace(stale Contact, contact Contact) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	index := rt.self.CommonPrefixLen(stale.ID)
+	bucket := rt.buckets[index]
+	for i, known := range bucket {
+		if known.ID == stale.ID {
+			bucket = append(bucket[:i], bucket[i+1:]...)
+			if rt.self.CommonPrefixLen(contact.ID) == index {
+				bucket = append(bucket, contact)
+			}
+			rt.buckets[index] = bucket
+			return
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to remove the contact from the routing table
+ * @param: node ID of the contact
+ **/
+
+func (rt *RoutingTable) Remove(id NodeID) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	index := rt.self.CommonPrefixLen(id)
+	if index == idBits {
+		return
+	}
+	bucket := rt.buckets[index]
+	for i, known := range bucket {
+		if known.ID == id {
+			rt.buckets[index] = append(bucket[:i], bucket[i+1:]...)
+			return
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the known contacts closest to the target
+ * @param: target node ID, count int
+ * @return: slice of contacts sorted by distance to the target
+ **/
+
+func (rt *RoutingTable) Closest(target NodeID, count int) []Contact {
+	contacts := rt.Contacts()
+	sortByDistance(contacts, target)
+	if len(contacts) > count {
+		contacts = contacts[:count]
+	}
+	return contacts
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get all the contacts of the routing table
+ * @return: slice of contacts
+ **/
+
+func (rt *RoutingTable) Contacts() []Contact {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var contacts []Contact
+	for _, bucket := range rt.buckets {
+		contacts = append(contacts, bucket...)
+	}
+	return contacts
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the number of contacts of the routing table
+ * @return: int
+ **/
+
+func (rt *RoutingTable) Len() int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	count := 0
+	for _, bucket := range rt.buckets {
+		count += len(bucket)
+	}
+	return count
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the index of the deepest bucket which is not empty
+ * @return: index of the bucket, -1 if the routing table is empty
+ **/
+
+func (rt *RoutingTable) deepestBucket() int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for i := idBits - 1; i >= 0; i-- {
+		if len(rt.buckets[i]) > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get a random node ID which falls in the given bucket, it is looked up to refresh the bucket
+ * @param: index of the bucket
+ * @return: node ID
+ **/
+
+func (rt *RoutingTable) randomIDInBucket(index int) NodeID {
+	id := randomNodeID()
+	for bit := 0; bit <= index && bit < idBits; bit++ {
+		mask := byte(0x80) >> uint(bit%8)
+		selfBit := rt.self[bit/8] & mask
+		if bit == index {
+			selfBit ^= mask // The first differing bit
+		}
+		id[bit/8] = id[bit/8]&^mask | selfBit
+	}
+	return id
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get a random node ID, it is used as the target of refresh lookups
+ * @return: node ID
+ **/
+
+func randomNodeID() NodeID {
+	var id NodeID
+	rand.Read(id[:])
+	return id
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to sort the contacts by their distance to the target
+ * @param: slice of contacts, target node ID
+ **/
+
+func sortByDistance(contacts []Contact, target NodeID) {
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].ID.Closer(contacts[j].ID, target) })
+}
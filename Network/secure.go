@@ -0,0 +1,203 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+const certificateLifetime = 10 * 365 * 24 * time.Hour // Validity of the self-signed certificate of the node
+
+var (
+	ErrUntrustedPeer = errors.New("peer key is not trusted")
+	ErrKeyMismatch   = errors.New("peer version does not match its certificate key")
+	ErrInvalidKey    = errors.New("invalid ed25519 public key")
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the self-signed TLS certificate of the node from its identity key
+ * @description: The peers do not check the certificate chain, they identify the node by the key of the certificate.
+ * @param: ed25519 private key
+ * @return: instance of TLS certificate and error if any
+ **/
+
+func newNodeCertificate(key ed25519.PrivateKey) (tls.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: NodeIDFromKey(pub).String()},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certificateLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the TLS configuration of the connections of the node
+ * @description: Both sides present their certificate, so every connection is authenticated in both directions.
+ * @return: instance of TLS config
+ **/
+
+func (n *Node) tlsConfig() *tls.Config {
+	n.mu.Lock()
+	cert := n.certificate
+	n.mu.Unlock()
+	return &tls.Config{
+		Certificates:          []tls.Certificate{cert},
+		ClientAuth:            tls.RequireAnyClientCert,
+		InsecureSkipVerify:    true, // The self-signed certificate is checked by verifyPeerCertificate
+		VerifyPeerCertificate: n.verifyPeerCertificate,
+		MinVersion:            tls.VersionTLS13,
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check the certificate of the peer during the TLS handshake
+ * @description: It must be a self-signed ed25519 certificate, and its key must be trusted if TrustedKeys is not empty.
+ * @param: raw certificates sent by the peer, verified chains (unused)
+ * @return: error if the peer must be refused
+ **/
+
+func (n *Node) verifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("peer sent no certificate")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+	pub, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return ErrInvalidKey
+	}
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		return err
+	}
+	if !n.isTrusted(pub) {
+		return fmt.Errorf("%w: %s", ErrUntrustedPeer, NodeIDFromKey(pub).Short())
+	}
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the peer key is allowed, every key is allowed when TrustedKeys is empty
+ * @param: ed25519 public key of the peer
+ * @return: bool
+ **/
+
+func (n *Node) isTrusted(pub ed25519.PublicKey) bool {
+	if len(n.TrustedKeys) == 0 {
+		return true
+	}
+	return n.TrustedKeys[NodeIDFromKey(pub)]
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to parse the hex public keys of the trusted peers
+ * @param: slice of hex public keys
+ * @return: set of the node IDs of the keys and error if a key is invalid
+ **/
+
+func ParseTrustedKeys(keys []string) (map[NodeID]bool, error) {
+	trusted := make(map[NodeID]bool)
+	for _, key := range keys {
+		pub, err := hex.DecodeString(strings.TrimSpace(key))
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+		trusted[NodeIDFromKey(pub)] = true
+	}
+	return trusted, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the hex public key of the node, the other nodes trust it with it
+ * @return: string
+ **/
+
+func (n *Node) PublicKey() string {
+	return hex.EncodeToString(n.Identity.Public().(ed25519.PublicKey))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to run the TLS handshake on a new connection
+ * @param: connection, inbound bool (the peer connected to us)
+ * @return: encrypted connection and error if the handshake failed or the peer was refused
+ **/
+
+func (n *Node) secureConn(conn net.Conn, inbound bool) (net.Conn, error) {
+	var secure *tls.Conn
+	if inbound {
+		secure = tls.Server(conn, n.tlsConfig())
+	} else {
+		secure = tls.Client(conn, n.tlsConfig())
+	}
+	ctx, cancel := context.WithTimeout(n.context(), handshakeTimeout)
+	defer cancel()
+	if err := secure.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return secure, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the public key which the peer authenticated with during the TLS handshake
+ * @param: connection
+ * @return: ed25519 public key, nil if the connection is not encrypted
+ **/
+
+func connPublicKey(conn net.Conn) ed25519.PublicKey {
+	secure, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := secure.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	pub, _ := state.PeerCertificates[0].PublicKey.(ed25519.PublicKey)
+	return pub
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check that the version of the peer announces the key it authenticated with
+ * @param: connection, version of the peer
+ * @return: error if the keys differ
+ **/
+
+func (n *Node) checkConnKey(conn net.Conn, version VersionInfo) error {
+	if !bytes.Equal(connPublicKey(conn), version.PublicKey) {
+		return ErrKeyMismatch
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package network
+
+import (
+	"log"
+)
+
+// NodeServices is advertised to the peers so they know which blocks the node can serve.
+type NodeServices struct {
+	FullBlocks    bool // The node keeps the transactions of every block
+	PrunedHeight  int  // The transactions of the blocks below this height were deleted
+	Height        int  // Number of blocks of the node
+	CompactBlocks bool // The node accepts new blocks as compact blocks
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the services which the node offers to its peers
+ * @return: instance of node services
+ **/
+
+func (n *Node) LocalServices() NodeServices {
+	prunedHeight := n.Blockchain.PrunedHeight()
+	return NodeServices{
+		FullBlocks:    prunedHeight == 0,
+		PrunedHeight:  prunedHeight,
+		Height:        n.Blockchain.Height(),
+		CompactBlocks: true,
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to advertise the services of the node to the other nodes,
+ * @description: a pruned node tells them that it cannot serve the transactions of old blocks
+ **/
+
+func (n *Node) AnnounceServices() {
+	msg := &Message{
+		Type:    "Services",
+		Payload: n.LocalServices(),
+		From:    n.Address,
+	}
+
+	for _, nodeAddr := range n.broadcastAddresses() {
+		go n.sendMessage(nodeAddr, msg)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the services advertised by the peer
+ * @param: address of the peer
+ * @return: instance of node services and false if the peer did not advertise any
+ **/
+
+func (n *Node) PeerServices(addr string) (NodeServices, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	services, ok := n.peerServices[addr]
+	return services, ok
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the peer can serve the full block at the given height
+ * @param: address of the peer, height int of the block
+ * @return: bool, peers which did not advertise their services are assumed to keep every block
+ **/
+
+func (n *Node) PeerCanServeBlock(addr string, height int) bool {
+	services, ok := n.PeerServices(addr)
+	return !ok || services.FullBlocks || height >= services.PrunedHeight
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to record the services advertised by the peer
+ * @param: address of the peer, instance of node services
+ **/
+
+func (n *Node) setPeerServices(addr string, services NodeServices) {
+	if addr == "" {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.peerServices == nil {
+		n.peerServices = make(map[string]NodeServices)
+	}
+	n.peerServices[addr] = services
+	log.Printf("Peer %s serves full blocks: %t (pruned below height %d)\n", addr, services.FullBlocks, services.PrunedHeight)
+}
@@ -0,0 +1,377 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+const simulationJoinBatch = 10                        // Nodes joining the DHT at the same time in a simulation
+const simulationPropagationTimeout = time.Minute      // How long the gossip simulation waits for an item to reach every node
+const simulationTransactions = 100                    // Transactions gossiped before the block of the gossip simulation is mined
+const simulationConnectAttempts = 5                   // Attempts of a node to join, a lossy memory network can fail a handshake
+const simulationPingInterval = 100 * time.Millisecond // Pings of the simulated nodes, frequent enough to measure the latency of a short run
+
+// SimulationReport summarizes a DHT simulation run.
+type SimulationReport struct {
+	Nodes            int
+	JoinDuration     time.Duration
+	AvgContacts      float64 // Average size of the routing tables
+	MinContacts      int
+	Lookups          int
+	Found            int     // Lookups which returned the target as the closest contact
+	AvgRounds        float64 // Average rounds of FindNode requests per lookup
+	AvgLookupLatency time.Duration
+	Connections      int
+}
+
+// GossipReport summarizes a gossip simulation run.
+type GossipReport struct {
+	Nodes          int
+	TxReached      int // Nodes which received the transaction
+	TxPropagation  time.Duration
+	BlockReached   int // Nodes which received the block
+	BlockPropagate time.Duration
+	BlockTxs       int
+	CompactBlocks  int // Compact blocks sent
+	CompactBytes   int // Bytes of the CmpctBlock, GetBlockTxn and BlockTxn frames sent
+	FullBlockBytes int // Bytes the same relays would have taken with whole blocks
+	Codec          string
+	TotalBytes     int            // Bytes of all the frames sent by the nodes
+	BlockFrames    map[string]int // Bytes of the Block frame of the mined block, by codec
+	MeasuredPeers  int            // Connections with a round-trip time, counted on both sides
+	AvgRTT         time.Duration  // Average of the smoothed round-trip times of the connections
+	MaxRTT         time.Duration  // Largest smoothed round-trip time of a connection
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to start the nodes of a simulation, they listen on consecutive local ports
+ * @description: of the memory network, or of TCP if it is nil.
+ * @param: number of nodes, first port, instance of memory network, codecs of the nodes (DefaultCodecs if empty)
+ * @return: slice of nodes, function stopping the nodes and error if a node could not be started
+ **/
+
+func startSimulationNodes(nodes int, basePort int, memnet *MemoryNetwork, codecs []string) ([]*Node, func(), error) {
+	var simNodes []*Node
+	stop := func() {
+		for _, node := range simNodes {
+			node.Stop()
+		}
+	}
+
+	for i := 0; i < nodes; i++ {
+		bc, err := MidLevelBlockchain.NewBlockchain(MidLevelBlockchain.NewMemoryBlockStore())
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		node := NewNode(bc, MidLevelBlockchain.NewMempool(bc), fmt.Sprintf("127.0.0.1:%d", basePort+i))
+		node.MaxInbound = nodes // A large simulation may connect more nodes to one node than the default allows
+		node.PingInterval = simulationPingInterval
+		if memnet != nil {
+			node.Transport = memnet.Transport(node.Address)
+		}
+		if len(codecs) > 0 {
+			node.Codecs = codecs
+		}
+		if err := node.Start(context.Background()); err != nil {
+			stop()
+			return nil, nil, err
+		}
+		simNodes = append(simNodes, node)
+	}
+	return simNodes, stop, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to retry the step of a simulation until it succeeds or simulationConnectAttempts failed
+ * @param: function of the step
+ * @return: error of the last attempt
+ **/
+
+func retrySimulation(step func() error) error {
+	var err error
+	for attempt := 0; attempt < simulationConnectAttempts; attempt++ {
+		if err = step(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to wait until the condition holds for every node
+ * @param: slice of nodes, condition function
+ * @return: number of nodes for which the condition holds and the time it took
+ **/
+
+func waitForNodes(nodes []*Node, condition func(*Node) bool) (int, time.Duration) {
+	start := time.Now()
+	for {
+		reached := 0
+		for _, node := range nodes {
+			if condition(node) {
+				reached++
+			}
+		}
+		if reached == len(nodes) || time.Since(start) > simulationPropagationTimeout {
+			return reached, time.Since(start)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to run an in-process simulation of the block and transaction gossip
+ * @description: The nodes are connected in a line, each one only to the next, so the items must be relayed hop by hop.
+ * @description: The first node announces transactions then mines them into a block, the report tells how long they
+ * @description: took to reach the last node and how many bytes the compact block relay saved. One transaction of the
+ * @description: block is not announced, so every node has to request it after the compact block.
+ * @param: number of nodes, first port, instance of memory network (nil for TCP)
+ * @return: instance of gossip report and error if a node could not be started or connected
+ **/
+
+func RunGossipSimulation(nodes int, basePort int, memnet *MemoryNetwork, codecs []string) (*GossipReport, error) {
+	if nodes < 2 {
+		return nil, fmt.Errorf("a simulation needs at least 2 nodes")
+	}
+	simNodes, stop, err := startSimulationNodes(nodes, basePort, memnet, codecs)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	for i := 1; i < nodes; i++ {
+		node, prev := simNodes[i], simNodes[i-1]
+		if err := retrySimulation(func() error { return node.AddPeer(prev.Address) }); err != nil {
+			return nil, err
+		}
+	}
+	// The dialed node registers its inbound peer after the dialer, the gossip must not start before it did
+	linked, _ := waitForNodes(simNodes, func(node *Node) bool {
+		want := 2
+		if node == simNodes[0] || node == simNodes[nodes-1] {
+			want = 1
+		}
+		return len(node.Peers()) >= want
+	})
+	if linked < nodes {
+		return nil, fmt.Errorf("only %d of %d nodes connected to their neighbours", linked, nodes)
+	}
+
+	report := &GossipReport{Nodes: nodes}
+	origin := simNodes[0]
+	var tx *MidLevelBlockchain.Transaction
+	for i := 0; i <= simulationTransactions; i++ {
+		tx = MidLevelBlockchain.NewTransaction(fmt.Sprintf("simulated transaction %d of %d", i, time.Now().UnixNano()))
+		if _, err := origin.Mempool.AddTransaction(tx); err != nil {
+			return nil, err
+		}
+		if i < simulationTransactions {
+			origin.BroadcastNewTransaction(tx.Encode())
+		}
+	}
+	unannounced := tx.Hash()
+	report.TxReached, report.TxPropagation = waitForNodes(simNodes, func(node *Node) bool {
+		return node == origin || node.Mempool.Count() == simulationTransactions
+	})
+	if origin.Mempool.FindTransaction(unannounced) == nil {
+		return nil, fmt.Errorf("the unannounced transaction left the mempool of the first node")
+	}
+
+	block := origin.produceBlock(origin.context(), true)
+	if block == nil {
+		return nil, fmt.Errorf("the first node could not mine a block")
+	}
+	report.BlockReached, report.BlockPropagate = waitForNodes(simNodes, func(node *Node) bool {
+		return node.haveItem(InvItem{Type: InvBlock, Hash: block.CurrentHash})
+	})
+
+	report.BlockTxs = len(block.Transactions)
+	report.Codec = negotiateCodec(simNodes[1].Codecs, origin.Codecs).Name()
+	report.BlockFrames = make(map[string]int)
+	for _, codec := range []Codec{JSONCodec{}, BinaryCodec{}} {
+		frame, err := origin.Messages.EncodeFrame(&Message{Type: "Block", Payload: block, From: origin.Address}, codec)
+		if err != nil {
+			return nil, err
+		}
+		report.BlockFrames[codec.Name()] = len(frame)
+	}
+	for _, node := range simNodes {
+		for _, counter := range node.Traffic() {
+			report.TotalBytes += counter.Bytes
+			switch counter.Type {
+			case "CmpctBlock":
+				report.CompactBlocks += counter.Messages
+				report.CompactBytes += counter.Bytes
+			case "GetBlockTxn", "BlockTxn":
+				report.CompactBytes += counter.Bytes
+			}
+		}
+	}
+	report.FullBlockBytes = report.CompactBlocks * report.BlockFrames[report.Codec]
+
+	// A short run can end before the first ping, every connection is given the time to answer one
+	waitForNodes(simNodes, func(node *Node) bool {
+		for _, metrics := range node.PeerMetrics() {
+			if metrics.Pongs == 0 {
+				return false
+			}
+		}
+		return true
+	})
+	var totalRTT time.Duration
+	for _, node := range simNodes {
+		for _, metrics := range node.PeerMetrics() {
+			if metrics.Pongs == 0 {
+				continue
+			}
+			report.MeasuredPeers++
+			totalRTT += metrics.AvgRTT
+			report.MaxRTT = max(report.MaxRTT, metrics.AvgRTT)
+		}
+	}
+	if report.MeasuredPeers > 0 {
+		report.AvgRTT = totalRTT / time.Duration(report.MeasuredPeers)
+	}
+	return report, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to format the gossip report
+ * @return: string
+ **/
+
+func (r *GossipReport) String() string {
+	saved := 0.0
+	if r.FullBlockBytes > 0 {
+		saved = 100 * float64(r.FullBlockBytes-r.CompactBytes) / float64(r.FullBlockBytes)
+	}
+	return fmt.Sprintf("Nodes: %d in a line\n"+
+		"Transactions reached %d/%d node(s) in %v\n"+
+		"Block of %d transactions reached %d/%d node(s) in %v\n"+
+		"Compact block relay: %d bytes for %d block(s), whole blocks: %d bytes (%.1f%% saved)\n"+
+		"Traffic: %d bytes in %s, block frame: %d bytes in json, %d bytes in binary\n"+
+		"Round-trip time: %v average, %v maximum over %d peer(s)",
+		r.Nodes,
+		r.TxReached, r.Nodes, r.TxPropagation.Round(time.Millisecond),
+		r.BlockTxs, r.BlockReached, r.Nodes, r.BlockPropagate.Round(time.Millisecond),
+		r.CompactBytes, r.CompactBlocks, r.FullBlockBytes, saved,
+		r.TotalBytes, r.Codec, r.BlockFrames["json"], r.BlockFrames["binary"],
+		r.AvgRTT.Round(time.Microsecond), r.MaxRTT.Round(time.Microsecond), r.MeasuredPeers)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to run an in-process simulation of the DHT
+ * @description: The nodes listen on consecutive local ports and all join through the first one, then random nodes
+ * @description: look up the IDs of other random nodes and the report tells how many lookups found their target.
+ * @description: On the memory network the nodes of the lookups are drawn from its seed, so a run can be repeated.
+ * @param: number of nodes, first port, number of lookups, instance of memory network (nil for TCP)
+ * @return: instance of simulation report and error if a node could not be started or joined
+ **/
+
+func RunDHTSimulation(nodes int, basePort int, lookups int, memnet *MemoryNetwork, codecs []string) (*SimulationReport, error) {
+	if nodes < 2 {
+		return nil, fmt.Errorf("a simulation needs at least 2 nodes")
+	}
+
+	simNodes, stop, err := startSimulationNodes(nodes, basePort, memnet, codecs)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	report := &SimulationReport{Nodes: nodes, Lookups: lookups}
+	seed := []string{simNodes[0].Address}
+	start := time.Now()
+	for batch := 1; batch < nodes; batch += simulationJoinBatch {
+		var wg sync.WaitGroup
+		errs := make(chan error, simulationJoinBatch)
+		for i := batch; i < batch+simulationJoinBatch && i < nodes; i++ {
+			wg.Add(1)
+			go func(node *Node) {
+				defer wg.Done()
+				if err := retrySimulation(func() error { return node.BootstrapDHT(seed) }); err != nil {
+					errs <- fmt.Errorf("node %s: %w", node.Address, err)
+				}
+			}(simNodes[i])
+		}
+		wg.Wait()
+		close(errs)
+		if err := <-errs; err != nil {
+			return nil, err
+		}
+	}
+	report.JoinDuration = time.Since(start)
+
+	report.MinContacts = -1
+	for _, node := range simNodes {
+		contacts := node.RoutingTable().Len()
+		report.AvgContacts += float64(contacts)
+		if report.MinContacts < 0 || contacts < report.MinContacts {
+			report.MinContacts = contacts
+		}
+		report.Connections += len(node.Peers())
+	}
+	report.AvgContacts /= float64(nodes)
+	report.Connections /= 2 // Each connection is counted by both of its ends
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	if memnet != nil {
+		rng = rand.New(rand.NewSource(memnet.seed))
+	}
+	var latency time.Duration
+	for i := 0; i < lookups; i++ {
+		source := simNodes[rng.Intn(nodes)]
+		target := simNodes[rng.Intn(nodes)]
+		for target == source {
+			target = simNodes[rng.Intn(nodes)]
+		}
+
+		begin := time.Now()
+		contacts, rounds := source.lookup(target.ID())
+		latency += time.Since(begin)
+		report.AvgRounds += float64(rounds)
+		if len(contacts) > 0 && contacts[0].ID == target.ID() {
+			report.Found++
+		}
+	}
+	if lookups > 0 {
+		report.AvgRounds /= float64(lookups)
+		report.AvgLookupLatency = latency / time.Duration(lookups)
+	}
+	return report, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to format the simulation report
+ * @return: string
+ **/
+
+func (r *SimulationReport) String() string {
+	success := 0.0
+	if r.Lookups > 0 {
+		success = 100 * float64(r.Found) / float64(r.Lookups)
+	}
+	return fmt.Sprintf("Nodes: %d (joined in %v)\n"+
+		"Routing table contacts: %.1f average, %d minimum\n"+
+		"Open connections: %d\n"+
+		"Lookups: %d, target found: %d (%.1f%%)\n"+
+		"Rounds per lookup: %.1f, average latency: %v",
+		r.Nodes, r.JoinDuration.Round(time.Millisecond),
+		r.AvgContacts, r.MinContacts,
+		r.Connections,
+		r.Lookups, r.Found, success,
+		r.AvgRounds, r.AvgLookupLatency.Round(time.Microsecond))
+}
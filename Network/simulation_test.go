@@ -0,0 +1,63 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+const testSimulationSeed = 1 // Seed of the memory network of the simulation tests, a failing run can be repeated
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the memory network of a simulation test, with a little latency
+ * @return: instance of memory network
+ **/
+
+func newTestMemoryNetwork() *MemoryNetwork {
+	memnet := NewMemoryNetwork(testSimulationSeed)
+	memnet.Latency = time.Millisecond
+	memnet.Jitter = time.Millisecond
+	return memnet
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that the DHT of the memory network converges: every routing table holds every other
+ * @description: node, since the network is smaller than a bucket, and every lookup finds its target.
+ **/
+
+func TestDHTSimulationConverges(t *testing.T) {
+	const nodes = 16
+	report, err := RunDHTSimulation(nodes, 30000, 2*nodes, newTestMemoryNetwork(), nil)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if report.MinContacts != nodes-1 {
+		t.Errorf("smallest routing table has %d contacts, want %d\n%s", report.MinContacts, nodes-1, report)
+	}
+	if report.Found != report.Lookups {
+		t.Errorf("%d of %d lookups found their target\n%s", report.Found, report.Lookups, report)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that the transactions and the block of the gossip simulation reach every node of the line
+ **/
+
+func TestGossipSimulationReachesEveryNode(t *testing.T) {
+	const nodes = 6
+	report, err := RunGossipSimulation(nodes, 30200, newTestMemoryNetwork(), nil)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if report.TxReached != nodes {
+		t.Errorf("transactions reached %d of %d nodes\n%s", report.TxReached, nodes, report)
+	}
+	if report.BlockReached != nodes {
+		t.Errorf("block reached %d of %d nodes\n%s", report.BlockReached, nodes, report)
+	}
+	if report.BlockTxs != simulationTransactions+1 {
+		t.Errorf("block has %d transactions, want %d", report.BlockTxs, simulationTransactions+1)
+	}
+}
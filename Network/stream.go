@@ -0,0 +1,592 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// After the handshake with a peer of streamsVersion or later, the frames are cut into chunks, so the frames of several
+// streams interleave on the connection.
+// Every chunk starts with this header, followed by its data:
+//
+//	magic (4 bytes) | stream (1 byte) | flags (1 byte) | sequence (8 bytes) | data length (4 bytes)
+//
+// The sequence is the offset of the data in the stream. The data of a window update is the offset up to which
+// the receiver handled the stream (8 bytes) followed by its window (4 bytes). All integers are big endian.
+const chunkHeaderSize = 4 + 1 + 1 + 8 + 4
+const windowUpdateSize = 8 + 4
+const maxChunkSize = 16 << 10  // Largest data of a chunk, a message of a higher stream waits for one chunk at most
+const streamWindow = 256 << 10 // Bytes a peer may send on a stream ahead of the messages handled
+
+// Chunk flags
+const (
+	chunkFirst        = 1 << 0 // First chunk of a frame
+	chunkLast         = 1 << 1 // Last chunk of a frame
+	chunkWindowUpdate = 1 << 2 // The chunk grants a window instead of carrying a frame
+)
+
+// StreamID identifies a logical stream of a peer connection, each message type is sent on the stream of its MessageType.
+type StreamID uint8
+
+// Streams of a connection, by priority: a stream is only written when the streams before it have nothing to send
+const (
+	StreamControl      StreamID = 0 // Pings, services, addresses, the DHT and the message types of applications
+	StreamTransactions StreamID = 1 // Transactions and the inventories announcing and requesting the items
+	StreamBlocks       StreamID = 2 // Relay of the new blocks, whole or compact, and their missing transactions
+	StreamSync         StreamID = 3 // Download of whole blocks, it gives way to every other stream
+	numStreams                  = 4
+)
+
+var streamNames = [numStreams]string{"control", "transactions", "blocks", "sync"}
+
+var (
+	ErrUnknownStream  = errors.New("chunk on an unknown stream")
+	ErrInvalidChunk   = errors.New("chunk or reassembled frame is malformed")
+	ErrWindowExceeded = errors.New("chunk exceeds the window of its stream")
+	ErrStreamMismatch = errors.New("message type sent on the wrong stream")
+)
+
+// Errors of the chunks and frames read from a peer which are penalized with penaltyInvalidFrame
+var invalidFrameErrors = []error{
+	ErrBadMagic, ErrBadChecksum, ErrFrameTooLarge, ErrCommandMismatch,
+	ErrUnknownStream, ErrInvalidChunk, ErrWindowExceeded, ErrStreamMismatch,
+}
+
+// chunk is a piece of a frame, or a window update, read from the connection.
+type chunk struct {
+	stream StreamID
+	flags  byte
+	seq    uint64
+	data   []byte
+}
+
+// sendStream is the sending side of a stream. Its messages are written in order by the write loop of the peer,
+// one chunk at a time, as far as the window granted by the peer allows.
+type sendStream struct {
+	queue   chan *queuedMessage
+	current *queuedMessage // Message being written, nil between messages
+	frame   []byte         // Frame of the current message
+	offset  int            // Bytes of the frame already written
+	chunks  int            // Chunks of the frame already written
+	sent    uint64         // Bytes written on the stream, the sequence of the next chunk
+
+	mu    sync.Mutex // Guards acked and limit, the read loop updates them
+	acked uint64     // Offset up to which the peer handled the stream
+	limit uint64     // Window of the peer
+}
+
+// recvStream is the receiving side of a stream. The read loop reassembles the frames from the chunks, and the handle
+// loop of the stream handles their messages in order, then grants their bytes back to the peer. The bytes of the frame
+// being reassembled are granted as they arrive once the messages before it were handled, so a frame larger than the
+// window still flows, while a stream whose messages are handled slowly is held back.
+type recvStream struct {
+	window   uint64 // Bytes the peer may send ahead of the messages handled
+	maxFrame int    // Largest frame of the message types of the stream
+	partial  []byte // Frame being reassembled, only used by the read loop
+	next     uint64 // Sequence of the next chunk of the stream, only used by the read loop
+
+	mu        sync.Mutex
+	inbox     []inboundMessage
+	received  uint64 // Offset following the last chunk read
+	queued    uint64 // Offset following the last message queued for the handle loop or dropped
+	consumed  uint64 // Offset up to which the stream was handled or granted
+	granted   uint64 // Offset sent in the last window update
+	announced bool   // The window was sent to the peer
+	signal    chan struct{}
+}
+
+// inboundMessage is a reassembled message waiting for the handle loop of its stream.
+type inboundMessage struct {
+	msg *Message // Nil for a dropped message, whose bytes are only granted back
+	end uint64   // Offset following the last chunk of the message
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the name of the stream
+ * @return: string
+ **/
+
+func (s StreamID) String() string {
+	if s < numStreams {
+		return streamNames[s]
+	}
+	return fmt.Sprintf("stream %d", uint8(s))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the stream the messages of the type are sent on
+ * @param: name of the message type
+ * @return: stream, StreamControl if the type is not registered
+ **/
+
+func (r *MessageRegistry) streamOf(name string) StreamID {
+	t, _ := r.Lookup(name)
+	return t.Stream
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the largest frame which can be received on the stream
+ * @param: stream
+ * @return: size in bytes, header included
+ **/
+
+func (r *MessageRegistry) maxStreamFrame(stream StreamID) int {
+	payload := uint32(defaultMaxPayloadSize) // Unknown types are read with the default limit
+	for _, t := range r.Types() {
+		if t.Stream == stream {
+			payload = max(payload, t.MaxPayload)
+		}
+	}
+	return frameHeaderSize + int(payload)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to create the sending and receiving sides of the streams of the peer
+ **/
+
+func (p *Peer) initStreams() {
+	for s := range p.out {
+		p.out[s] = &sendStream{queue: make(chan *queuedMessage, peerSendQueueSize), limit: streamWindow}
+		p.in[s] = &recvStream{window: streamWindow, maxFrame: p.node.Messages.maxStreamFrame(StreamID(s)), signal: make(chan struct{}, 1)}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to wake up the write loop of the peer, it does not block
+ **/
+
+func (p *Peer) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the chunk, header included
+ * @param: stream, flags, sequence, data
+ * @return: byte slice of the chunk
+ **/
+
+func encodeChunk(stream StreamID, flags byte, seq uint64, data []byte) []byte {
+	buf := make([]byte, chunkHeaderSize+len(data))
+	binary.BigEndian.PutUint32(buf[0:4], protocolMagic)
+	buf[4] = byte(stream)
+	buf[5] = flags
+	binary.BigEndian.PutUint64(buf[6:14], seq)
+	binary.BigEndian.PutUint32(buf[14:chunkHeaderSize], uint32(len(data)))
+	copy(buf[chunkHeaderSize:], data)
+	return buf
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read the next chunk from the connection
+ * @param: reader of the connection
+ * @return: instance of chunk and error if any, io.EOF if the connection was closed between chunks
+ **/
+
+func readChunk(r io.Reader) (chunk, error) {
+	header := make([]byte, chunkHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return chunk{}, err
+	}
+	if binary.BigEndian.Uint32(header[0:4]) != protocolMagic {
+		return chunk{}, ErrBadMagic
+	}
+	c := chunk{stream: StreamID(header[4]), flags: header[5], seq: binary.BigEndian.Uint64(header[6:14])}
+	if c.stream >= numStreams {
+		return chunk{}, fmt.Errorf("%w: %d", ErrUnknownStream, header[4])
+	}
+	length := binary.BigEndian.Uint32(header[14:chunkHeaderSize])
+	if length > maxChunkSize {
+		return chunk{}, fmt.Errorf("%w: chunk of %d bytes", ErrFrameTooLarge, length)
+	}
+	if c.flags&chunkWindowUpdate != 0 && length != windowUpdateSize {
+		return chunk{}, fmt.Errorf("%w: window update of %d bytes", ErrInvalidChunk, length)
+	}
+
+	c.data = make([]byte, length)
+	if _, err := io.ReadFull(r, c.data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return chunk{}, err
+	}
+	return c, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to write a chunk to the connection of the peer
+ * @param: stream, flags, sequence, data
+ * @return: error if any
+ **/
+
+func (p *Peer) writeChunk(stream StreamID, flags byte, seq uint64, data []byte) error {
+	p.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := p.conn.Write(encodeChunk(stream, flags, seq, data))
+	return err
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to write the next chunk, the due window updates go first, then the chunks of
+ * @description: the streams by priority. A stream whose window is used up is skipped until the peer grants more.
+ * @return: false if there was nothing to write, error if the write failed
+ **/
+
+func (p *Peer) writeNext() (bool, error) {
+	for s, in := range p.in {
+		if update := in.takeUpdate(); update != nil {
+			return true, p.writeChunk(StreamID(s), chunkWindowUpdate, 0, update)
+		}
+	}
+
+	for s, out := range p.out {
+		if out.current == nil && !p.nextMessage(out) {
+			continue
+		}
+		size := min(len(out.frame)-out.offset, maxChunkSize, int(min(out.window(), maxChunkSize)))
+		if size == 0 {
+			continue
+		}
+
+		var flags byte
+		if out.offset == 0 {
+			flags |= chunkFirst
+		}
+		if out.offset+size == len(out.frame) {
+			flags |= chunkLast
+		}
+		if err := p.writeChunk(StreamID(s), flags, out.sent, out.frame[out.offset:out.offset+size]); err != nil {
+			if !p.Closed() {
+				log.Printf("Error sending %s message to peer %s: %v\n", out.current.msg.Type, p.Address(), err)
+			}
+			out.finish(err)
+			return true, err
+		}
+		out.offset += size
+		out.sent += uint64(size)
+		out.chunks++
+		if out.offset == len(out.frame) {
+			p.node.traffic.record(out.current.msg.Type, len(out.frame)+out.chunks*chunkHeaderSize)
+			out.finish(nil)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to take the next queued message of the stream and encode its frame
+ * @description: A message which cannot be encoded is dropped and the next one is taken.
+ * @param: sending side of the stream
+ * @return: false if the queue of the stream is empty
+ **/
+
+func (p *Peer) nextMessage(out *sendStream) bool {
+	for {
+		select {
+		case item := <-out.queue:
+			frame, err := p.node.Messages.EncodeFrame(item.msg, p.Codec)
+			if err != nil {
+				log.Printf("Error encoding %s message for peer %s: %v\n", item.msg.Type, p.Address(), err)
+				if item.written != nil {
+					item.written <- err
+				}
+				continue // The connection is fine, only this message is dropped
+			}
+			out.current, out.frame, out.offset, out.chunks = item, frame, 0, 0
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to end the current message of the stream and report its write to the sender
+ * @param: error of the write, nil if the whole frame was written
+ **/
+
+func (out *sendStream) finish(err error) {
+	if out.current.written != nil {
+		out.current.written <- err
+	}
+	out.current, out.frame = nil, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the bytes which can be written on the stream before the peer grants more
+ * @return: available bytes
+ **/
+
+func (out *sendStream) window() uint64 {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	inFlight := out.sent - min(out.acked, out.sent)
+	if inFlight >= out.limit {
+		return 0
+	}
+	return out.limit - inFlight
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to apply the window update of the peer
+ * @param: data of the window update chunk
+ **/
+
+func (out *sendStream) update(data []byte) {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.acked = max(out.acked, binary.BigEndian.Uint64(data[0:8]))
+	out.limit = uint64(binary.BigEndian.Uint32(data[8:windowUpdateSize]))
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the peer has nothing left to write
+ * @return: bool
+ **/
+
+func (p *Peer) idle() bool {
+	for _, out := range p.out {
+		if out.current != nil || len(out.queue) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to take the window update which is due on the stream
+ * @description: The window is sent once at the start, then again when a quarter of it was granted or everything read
+ * @description: was handled, so a peer sending small messages is not stalled waiting for the quarter.
+ * @return: data of the window update chunk, nil if none is due
+ **/
+
+func (in *recvStream) takeUpdate() []byte {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	settled := in.consumed == in.received && in.received == in.queued
+	if in.announced && (in.consumed == in.granted || (in.consumed-in.granted < in.window/4 && !settled)) {
+		return nil
+	}
+	in.announced = true
+	in.granted = in.consumed
+	data := make([]byte, windowUpdateSize)
+	binary.BigEndian.PutUint64(data[0:8], in.consumed)
+	binary.BigEndian.PutUint32(data[8:windowUpdateSize], uint32(in.window))
+	return data
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to record that the stream was handled up to the offset
+ * @param: offset following the message which was handled
+ **/
+
+func (in *recvStream) consume(end uint64) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.consumed = max(in.consumed, end)
+	in.grantPartial()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to record a chunk read on the stream, it must be called with the lock held
+ * @param: offset following the chunk, true if the chunk ends a message which is queued or dropped
+ **/
+
+func (in *recvStream) receive(end uint64, complete bool) {
+	in.received = max(in.received, end)
+	if complete {
+		in.queued = max(in.queued, end)
+	}
+	in.grantPartial()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to grant the bytes of the frame being reassembled once every message before it
+ * @description: was handled, it must be called with the lock held
+ **/
+
+func (in *recvStream) grantPartial() {
+	if in.consumed >= in.queued {
+		in.consumed = max(in.consumed, in.received)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to queue the message for the handle loop of the stream
+ * @param: instance of inbound message
+ **/
+
+func (in *recvStream) push(item inboundMessage) {
+	in.mu.Lock()
+	in.inbox = append(in.inbox, item)
+	in.receive(item.end, true)
+	in.mu.Unlock()
+	select {
+	case in.signal <- struct{}{}:
+	default:
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to drop the message ending at the offset, its bytes are granted back in order
+ * @description: with the messages queued before it
+ * @param: offset following the message
+ **/
+
+func (in *recvStream) drop(end uint64) {
+	in.push(inboundMessage{end: end})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to take the next message to handle on the stream
+ * @return: instance of inbound message and false if the stream has none
+ **/
+
+func (in *recvStream) pop() (inboundMessage, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if len(in.inbox) == 0 {
+		return inboundMessage{}, false
+	}
+	item := in.inbox[0]
+	in.inbox[0] = inboundMessage{}
+	in.inbox = in.inbox[1:]
+	return item, true
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to process a chunk read from the peer
+ * @description: Window updates open the stream to more writes. Data chunks are checked against the window of their
+ * @description: stream and added to its partial frame; the last chunk completes the frame, whose message is queued for
+ * @description: the handle loop of the stream. A chunk out of sequence breaks the protocol, the connection cannot lose one.
+ * @description: The write loop is woken up after each chunk, in case the chunk made a window update due.
+ * @param: instance of chunk
+ * @return: error if the chunk or its frame breaks the protocol, the peer is then disconnected
+ **/
+
+func (p *Peer) receiveChunk(c chunk) error {
+	if c.flags&chunkWindowUpdate != 0 {
+		p.out[c.stream].update(c.data)
+		p.signal()
+		return nil
+	}
+
+	in := p.in[c.stream]
+	end := c.seq + uint64(len(c.data))
+	in.mu.Lock()
+	exceeded := end > in.consumed && end-in.consumed > in.window
+	in.mu.Unlock()
+	if exceeded {
+		return fmt.Errorf("%w: %s stream", ErrWindowExceeded, c.stream)
+	}
+
+	// The connection is a reliable stream, a chunk out of sequence is a protocol violation
+	if c.seq != in.next {
+		return fmt.Errorf("%w: chunk at offset %d of the %s stream, %d expected", ErrInvalidChunk, c.seq, c.stream, in.next)
+	}
+	switch {
+	case c.flags&chunkFirst != 0 && in.partial == nil:
+		in.partial = c.data
+	case c.flags&chunkFirst == 0 && in.partial != nil:
+		in.partial = append(in.partial, c.data...)
+	case in.partial == nil:
+		return fmt.Errorf("%w: chunk of a frame which was not started on the %s stream", ErrInvalidChunk, c.stream)
+	default:
+		return fmt.Errorf("%w: frame started before the last one ended on the %s stream", ErrInvalidChunk, c.stream)
+	}
+	if len(in.partial) > in.maxFrame {
+		return fmt.Errorf("%w: frame of more than %d bytes on the %s stream", ErrFrameTooLarge, in.maxFrame, c.stream)
+	}
+	in.next = end
+	defer p.signal()
+	if c.flags&chunkLast == 0 {
+		in.mu.Lock()
+		in.receive(end, false)
+		in.mu.Unlock()
+		return nil
+	}
+	frame := in.partial
+	in.partial = nil
+
+	reader := bytes.NewReader(frame)
+	msg, err := p.node.Messages.ReadFrame(reader, p.Codec)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: frame of %d bytes is cut short", ErrInvalidChunk, len(frame))
+	}
+	if err != nil {
+		return err
+	}
+	if reader.Len() > 0 {
+		return fmt.Errorf("%w: %d bytes after the frame", ErrInvalidChunk, reader.Len())
+	}
+	if t, ok := p.node.Messages.Lookup(msg.Type); ok && t.Stream != c.stream {
+		return fmt.Errorf("%w: %s on the %s stream", ErrStreamMismatch, msg.Type, c.stream)
+	}
+
+	msg.From = p.Address() // The handshake tells who the sender is
+	if !p.messages.Allow(1) {
+		p.node.Misbehaving(p.Address(), penaltyRateLimited, "message rate limit exceeded")
+		in.drop(end)
+		return nil
+	}
+	in.push(inboundMessage{msg: msg, end: end})
+	return nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is the handle loop of a stream, it handles the messages of the stream in order
+ * @description: Each stream has its own loop, so a slow block does not hold up the pings and the transactions.
+ * @param: receiving side of the stream
+ **/
+
+func (p *Peer) handleLoop(in *recvStream) {
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-in.signal:
+		}
+		for !p.Closed() {
+			item, ok := in.pop()
+			if !ok {
+				break
+			}
+			if item.msg != nil {
+				p.node.handleMessage(item.msg)
+			}
+			in.consume(item.end)
+			p.signal()
+		}
+	}
+}
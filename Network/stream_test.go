@@ -0,0 +1,52 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"net"
+	"testing"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that the chunks of a stream must follow each other: a gap in the sequence, a chunk
+ * @description: of a frame which was not started and a frame started before the last one ended break the protocol
+ **/
+
+func TestChunkOutOfSequence(t *testing.T) {
+	bc, err := MidLevelBlockchain.NewBlockchain(MidLevelBlockchain.NewMemoryBlockStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := NewNode(bc, MidLevelBlockchain.NewMempool(bc), "127.0.0.1:31000")
+	version := node.localVersion()
+	version.PublicKey = GenerateIdentity().Public().(ed25519.PublicKey)
+
+	cases := []struct {
+		name   string
+		chunks []chunk
+	}{
+		{"gap", []chunk{{flags: chunkFirst, data: make([]byte, 8)}, {seq: 16, data: make([]byte, 8)}}},
+		{"not started", []chunk{{data: make([]byte, 8)}}},
+		{"started twice", []chunk{{flags: chunkFirst, data: make([]byte, 8)}, {flags: chunkFirst, seq: 8, data: make([]byte, 8)}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conn, other := net.Pipe()
+			defer conn.Close()
+			defer other.Close()
+			peer := newPeer(node, conn, false, version)
+			var err error
+			for _, chunk := range c.chunks {
+				if err = peer.receiveChunk(chunk); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, ErrInvalidChunk) {
+				t.Fatalf("receiving the chunks returned %v, want %v", err, ErrInvalidChunk)
+			}
+		})
+	}
+}
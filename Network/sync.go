@@ -0,0 +1,364 @@
+package network
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+const maxInvItems = 500                   // Items in a single Inv, GetData or NotFound message
+const maxBlocksPerRequest = 64            // Blocks announced in reply to a single GetBlocks
+const syncStallTimeout = 30 * time.Second // A sync peer which sends nothing for this long is replaced
+
+// Inventory item types
+const (
+	InvBlock = "block"
+	InvTx    = "tx"
+)
+
+// GetBlocksPayload asks a peer for the hashes of the blocks following the last block of the locator it knows.
+type GetBlocksPayload struct {
+	Locator  []string
+	StopHash string `json:",omitempty"`
+}
+
+// InvItem identifies a block or a transaction by its hash.
+type InvItem struct {
+	Type string
+	Hash string
+}
+
+// InvPayload is the list of items of an Inv, GetData or NotFound message.
+type InvPayload struct {
+	Items []InvItem
+}
+
+// syncState tracks the initial block download from a single peer.
+type syncState struct {
+	peer         string          // Peer the blocks are downloaded from, empty when the node is not syncing
+	pending      map[string]bool // Blocks requested with GetData which did not arrive yet
+	requestID    uint64          // Request ID of the last GetBlocks, the Inv replying to it carries it
+	lastProgress time.Time
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to check if the node is downloading blocks from a peer
+ * @return: address of the sync peer and bool
+ **/
+
+func (n *Node) Syncing() (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.sync.peer, n.sync.peer != ""
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the best height known for the peer, from its handshake and its announced services
+ * @param: address of the peer
+ * @return: height int
+ **/
+
+func (n *Node) peerHeight(addr string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	height := n.peerServices[addr].Height
+	if peer, ok := n.peerAt(addr); ok && peer.Version.BestHeight > height {
+		height = peer.Version.BestHeight
+	}
+	return height
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to start a sync with the best peer if one has more blocks than the node
+ * @description: A sync peer which disconnected or stalled is replaced.
+ **/
+
+func (n *Node) checkSync() {
+	height := n.Blockchain.Height()
+
+	n.mu.Lock()
+	current := n.sync.peer
+	stalled := current != "" && time.Since(n.sync.lastProgress) > syncStallTimeout
+	_, connected := n.peerAt(current)
+	n.mu.Unlock()
+
+	if current != "" {
+		if connected && !stalled {
+			return
+		}
+		log.Printf("Sync peer %s stalled or disconnected\n", current)
+		n.finishSync(current)
+	}
+
+	best, bestHeight := "", height
+	for _, peer := range n.Peers() {
+		addr := peer.Address()
+		if peerHeight := n.peerHeight(addr); peerHeight > bestHeight && n.PeerCanServeBlock(addr, height) {
+			best, bestHeight = addr, peerHeight
+		}
+	}
+	if best != "" {
+		n.startSync(best)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to start downloading the missing blocks from the peer, unless a sync is running
+ * @param: address of the peer
+ **/
+
+func (n *Node) startSync(addr string) {
+	n.mu.Lock()
+	if n.sync.peer != "" {
+		n.mu.Unlock()
+		return
+	}
+	n.sync = syncState{peer: addr, pending: make(map[string]bool), lastProgress: time.Now()}
+	n.mu.Unlock()
+
+	log.Printf("Synchronizing the chain from peer %s (local height %d)\n", addr, n.Blockchain.Height())
+	n.requestBlocks(addr)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to stop the sync with the peer, the peers are told the new height of the node
+ * @param: address of the sync peer
+ **/
+
+func (n *Node) finishSync(addr string) {
+	n.mu.Lock()
+	if n.sync.peer != addr {
+		n.mu.Unlock()
+		return
+	}
+	n.sync = syncState{}
+	n.mu.Unlock()
+	n.AnnounceServices()
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to ask the peer for the blocks following the tip of the node
+ * @param: address of the peer
+ **/
+
+func (n *Node) requestBlocks(addr string) {
+	locator := n.Blockchain.Locator()
+	id := atomic.AddUint64(&n.nextRequestID, 1)
+	n.mu.Lock()
+	n.sync.requestID = id
+	n.mu.Unlock()
+	n.sendMessage(addr, &Message{Type: "GetBlocks", Payload: GetBlocksPayload{Locator: locator}, From: n.Address, RequestID: id})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the inventory message to the peer
+ * @param: address of the peer, message type (Inv, GetData or NotFound), slice of items
+ **/
+
+func (n *Node) sendInventory(addr string, msgType string, items []InvItem) {
+	n.sendInventoryReply(addr, msgType, items, 0)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the inventory message to the peer in reply to its request
+ * @param: address of the peer, message type, slice of items, request ID of the request (zero for none)
+ **/
+
+func (n *Node) sendInventoryReply(addr string, msgType string, items []InvItem, requestID uint64) {
+	for {
+		batch := items
+		if len(batch) > maxInvItems {
+			batch = batch[:maxInvItems]
+		}
+		items = items[len(batch):]
+
+		n.sendMessage(addr, &Message{Type: msgType, Payload: InvPayload{Items: batch}, From: n.Address, RequestID: requestID})
+		if len(items) == 0 {
+			return // An empty reply to GetBlocks tells the peer there is nothing more to download
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to reply to GetBlocks with the hashes of the blocks the peer is missing
+ * @param: instance of message, instance of get blocks payload
+ **/
+
+func (n *Node) handleGetBlocks(msg *Message, payload GetBlocksPayload) {
+	var items []InvItem
+	for _, block := range n.Blockchain.BlocksAfter(payload.Locator, payload.StopHash, maxBlocksPerRequest) {
+		items = append(items, InvItem{Type: InvBlock, Hash: block.CurrentHash})
+	}
+	n.sendInventoryReply(msg.From, "Inv", items, msg.RequestID)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to handle the inventory of the peer
+ * @description: The reply to our GetBlocks lists the next blocks of the sync, an inventory from the sync peer without
+ * @description: any unknown block means the node caught up with the peer. Other inventories are gossip announcements,
+ * @description: the items the node does not have yet are requested.
+ * @param: instance of message, instance of inventory payload
+ **/
+
+func (n *Node) handleInv(msg *Message, payload InvPayload) {
+	addr := msg.From
+	for _, item := range payload.Items {
+		if item.Type == InvBlock {
+			n.confirmReceipt(addr, item.Hash)
+		}
+	}
+	n.mu.Lock()
+	syncReply := n.sync.peer == addr && msg.RequestID != 0 && msg.RequestID == n.sync.requestID
+	n.mu.Unlock()
+	if !syncReply {
+		n.fetchAnnounced(addr, payload.Items)
+		return
+	}
+
+	var wanted []InvItem
+	for _, item := range payload.Items {
+		if item.Type == InvBlock && !n.haveItem(item) {
+			wanted = append(wanted, item)
+		}
+	}
+	n.mu.Lock()
+	n.sync.lastProgress = time.Now()
+	for _, item := range wanted {
+		n.sync.pending[item.Hash] = true
+	}
+	n.mu.Unlock()
+
+	if len(wanted) == 0 {
+		log.Printf("Chain synchronized with peer %s at height %d\n", addr, n.Blockchain.Height())
+		n.finishSync(addr)
+		return
+	}
+	n.sendInventory(addr, "GetData", wanted)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to send the requested blocks and transactions, the unknown or pruned ones are listed in a NotFound
+ * @param: address of the peer, instance of inventory payload
+ **/
+
+func (n *Node) handleGetData(addr string, payload InvPayload) {
+	var notFound []InvItem
+	for _, item := range payload.Items {
+		if item.Type == InvTx {
+			if !n.sendTransaction(addr, item.Hash) {
+				notFound = append(notFound, item)
+			}
+			continue
+		}
+		if item.Type != InvBlock {
+			continue
+		}
+		n.confirmReceipt(addr, item.Hash)
+
+		block := n.Blockchain.BlockByHash(item.Hash)
+		if block == nil || block.Pruned {
+			notFound = append(notFound, item)
+			continue
+		}
+		n.sendMessage(addr, &Message{Type: "Block", Payload: block, From: n.Address})
+	}
+	if len(notFound) > 0 {
+		n.sendInventory(addr, "NotFound", notFound)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to give up the sync with a peer which cannot serve the requested blocks
+ * @param: address of the peer, instance of inventory payload
+ **/
+
+func (n *Node) handleNotFound(addr string, payload InvPayload) {
+	if syncPeer, ok := n.Syncing(); ok && syncPeer == addr {
+		log.Printf("Peer %s cannot serve %d block(s), looking for another sync peer\n", addr, len(payload.Items))
+		n.finishSync(addr)
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to validate the block received from the peer and add it on top of the chain
+ * @description: A new block is announced to the other peers, unless it was downloaded by the sync. A block whose
+ * @description: parent is unknown means the node is behind the peer, it starts a sync with the peer. A first block
+ * @description: other than ours means the peer is on another chain which never links to ours, it is disconnected.
+ * @param: instance of block, address of the peer
+ * @return: error if the block was refused
+ **/
+
+func (n *Node) processBlock(block *MidLevelBlockchain.Block, from string) error {
+	item := InvItem{Type: InvBlock, Hash: block.CurrentHash}
+	n.markSeen(item)
+
+	err := n.Blockchain.AcceptBlock(block)
+	known := errors.Is(err, MidLevelBlockchain.ErrKnownBlock)
+
+	n.mu.Lock()
+	syncBlock := n.sync.peer == from && n.sync.pending[block.CurrentHash]
+	if syncBlock {
+		delete(n.sync.pending, block.CurrentHash)
+		n.sync.lastProgress = time.Now()
+	}
+	batchDone := syncBlock && len(n.sync.pending) == 0
+	n.mu.Unlock()
+
+	switch {
+	case known:
+		// Already received from another peer
+	case errors.Is(err, MidLevelBlockchain.ErrInvalidBlock), errors.Is(err, MidLevelBlockchain.ErrInsufficientWork):
+		if syncBlock {
+			n.finishSync(from)
+		}
+		n.Misbehaving(from, penaltyInvalidBlock, err.Error())
+		return err
+	case errors.Is(err, MidLevelBlockchain.ErrUnknownParent) && block.PreviousHash == "":
+		log.Printf("Peer %s is on a chain with another first block, disconnecting\n", from)
+		n.finishSync(from)
+		n.disconnect(from)
+		return err
+	case errors.Is(err, MidLevelBlockchain.ErrUnknownParent):
+		if syncBlock {
+			log.Printf("Peer %s sent a block which does not extend the chain, stopping the sync\n", from)
+			n.finishSync(from)
+		} else if from != "" {
+			n.startSync(from)
+		}
+		return err
+	case err != nil:
+		if syncBlock {
+			n.finishSync(from)
+		}
+		return err
+	default:
+		if n.Mempool != nil {
+			n.Mempool.RemoveBlockTransactions(block)
+		}
+		if !syncBlock {
+			log.Println("New block added")
+			n.relayBlock(block, from)
+		}
+	}
+
+	if batchDone {
+		n.requestBlocks(from) // Ask for the next batch until the peer has nothing more
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	MidLevelBlockchain "github.com/Ammar123890/Mid-Level-Blockchain/MidLevelBlockchain"
+)
+
+const testBlocks = 8                     // Blocks mined on each side of a concurrency test, the difficulty grows with the height
+const testSyncTimeout = 30 * time.Second // How long the nodes of a test are given to agree on the tip
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that blocks delivered through the message handler, as from a peer, while blocks are
+ * @description: mined locally and the chain is read from the sync and gossip paths, keep a single valid chain. Half of the
+ * @description: delivered blocks are built on the tip of the node, the others on another chain and are refused.
+ **/
+
+func TestConcurrentBlockDelivery(t *testing.T) {
+	bc, err := MidLevelBlockchain.NewBlockchain(MidLevelBlockchain.NewMemoryBlockStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := MidLevelBlockchain.NewBlockchain(MidLevelBlockchain.NewMemoryBlockStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := NewNode(bc, MidLevelBlockchain.NewMempool(bc), "127.0.0.1:30300")
+	node.Transport = NewMemoryNetwork(testSimulationSeed).Transport(node.Address) // Sync requests to the sender fail at once
+
+	var writers, readers sync.WaitGroup
+	var accepted, refused, minedLocally int
+	done := make(chan struct{})
+
+	// Network path: the blocks arrive through the message handler
+	writers.Add(1)
+	go func() {
+		defer writers.Done()
+		for i := 0; i < testBlocks; i++ {
+			var block *MidLevelBlockchain.Block
+			if i%2 == 0 {
+				block = bc.MineOnTip([]string{fmt.Sprintf("remote transaction %d", i)})
+			} else {
+				block = other.ForceMineBlock([]string{fmt.Sprintf("remote transaction %d", i)}, other.LatestHash())
+			}
+			data, err := json.Marshal(block)
+			if err != nil {
+				t.Errorf("encoding block %d: %v", i, err)
+				return
+			}
+			node.handleMessage(&Message{Type: "Block", Data: data, From: "127.0.0.1:30301"})
+			if bc.HeightOf(block.CurrentHash) >= 0 {
+				accepted++
+			} else {
+				refused++
+			}
+		}
+	}()
+
+	// CLI path: blocks are mined on the node
+	writers.Add(1)
+	go func() {
+		defer writers.Done()
+		for i := 0; i < testBlocks; i++ {
+			if bc.ForceMineBlock([]string{fmt.Sprintf("local transaction %d", i)}, bc.LatestHash()) != nil {
+				minedLocally++
+			}
+		}
+	}()
+
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				bc.VerifyChain()
+				bc.BlocksAfter(bc.Locator(), "", maxBlocksPerRequest)
+				node.haveItem(InvItem{Type: InvBlock, Hash: bc.LatestHash()})
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	if accepted+refused != testBlocks {
+		t.Errorf("%d blocks accepted and %d refused, %d were delivered", accepted, refused, testBlocks)
+	}
+	if refused < testBlocks/2 {
+		t.Errorf("%d blocks refused, the %d blocks of the other chain must be", refused, testBlocks/2)
+	}
+	if bc.Height() != accepted+minedLocally {
+		t.Errorf("chain height is %d, %d blocks were accepted and %d mined", bc.Height(), accepted, minedLocally)
+	}
+	if !bc.VerifyChain() {
+		t.Error("the chain does not verify")
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function tests that nodes agree on the tip when one node mines and broadcasts blocks while a connected
+ * @description: node follows the gossip and another one connects in the middle and has to sync the blocks it missed
+ **/
+
+func TestSyncAndBroadcastWhileMining(t *testing.T) {
+	nodes, stop, err := startSimulationNodes(3, 30400, newTestMemoryNetwork(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	miner, follower, late := nodes[0], nodes[1], nodes[2]
+	if err := follower.AddPeer(miner.Address); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < testBlocks; i++ {
+			block := miner.Blockchain.ForceMineBlock([]string{fmt.Sprintf("transaction %d", i)}, miner.Blockchain.LatestHash())
+			if block == nil {
+				t.Errorf("mining block %d failed", i)
+				return
+			}
+			miner.BroadcastNewBlock(block)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for miner.Blockchain.Height() < testBlocks/2 {
+			time.Sleep(time.Millisecond)
+		}
+		if err := late.AddPeer(miner.Address); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+
+	tip := miner.Blockchain.LatestHash()
+	agreed, _ := waitForTip(nodes, tip, testSyncTimeout)
+	if !agreed {
+		for _, node := range nodes {
+			t.Logf("node %s: height %d, tip %s", node.Address, node.Blockchain.Height(), node.Blockchain.LatestHash())
+		}
+		t.Fatalf("the nodes did not reach the tip %s of the miner", tip)
+	}
+	for _, node := range nodes {
+		if !node.Blockchain.VerifyChain() {
+			t.Errorf("the chain of node %s does not verify", node.Address)
+		}
+	}
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to wait until every node has the tip
+ * @param: slice of nodes, hash of the tip, timeout
+ * @return: false if a node did not reach the tip in time, and the time it took
+ **/
+
+func waitForTip(nodes []*Node, tip string, timeout time.Duration) (bool, time.Duration) {
+	start := time.Now()
+	for time.Since(start) < timeout {
+		reached := 0
+		for _, node := range nodes {
+			if node.Blockchain.LatestHash() == tip {
+				reached++
+			}
+		}
+		if reached == len(nodes) {
+			return true, time.Since(start)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false, time.Since(start)
+}
@@ -0,0 +1,57 @@
+package network
+
+import (
+	"sort"
+	"sync"
+)
+
+// TrafficCounter counts the frames of a message type sent by the node.
+type TrafficCounter struct {
+	Type     string
+	Messages int
+	Bytes    int // Frame headers included
+}
+
+// trafficStats counts the frames sent by the node, by message type.
+type trafficStats struct {
+	mu       sync.Mutex
+	counters map[string]*TrafficCounter
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to count the frame which was written to a peer
+ * @param: message type string, size int of the frame
+ **/
+
+func (t *trafficStats) record(msgType string, size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counters == nil {
+		t.counters = make(map[string]*TrafficCounter)
+	}
+	counter, ok := t.counters[msgType]
+	if !ok {
+		counter = &TrafficCounter{Type: msgType}
+		t.counters[msgType] = counter
+	}
+	counter.Messages++
+	counter.Bytes += size
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to get the frames sent by the node since it started, by message type
+ * @return: slice of traffic counters sorted by message type
+ **/
+
+func (n *Node) Traffic() []TrafficCounter {
+	n.traffic.mu.Lock()
+	defer n.traffic.mu.Unlock()
+	counters := make([]TrafficCounter, 0, len(n.traffic.counters))
+	for _, counter := range n.traffic.counters {
+		counters = append(counters, *counter)
+	}
+	sort.Slice(counters, func(i, j int) bool { return counters[i].Type < counters[j].Type })
+	return counters
+}
@@ -0,0 +1,37 @@
+package network
+
+import (
+	"net"
+	"time"
+)
+
+// Transport opens the connections of the node, the node runs TLS, its handshakes and its protocol on top of them.
+type Transport interface {
+	Listen(addr string) (net.Listener, error)                  // Listens for the connections of the other nodes
+	Dial(addr string, timeout time.Duration) (net.Conn, error) // Opens a connection to the node listening on the address
+}
+
+// TCPTransport is the transport of a real network, the connections are encrypted with TLS by the node.
+type TCPTransport struct{}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to listen for TCP connections on the address
+ * @param: address string (host:port)
+ * @return: listener and error if any
+ **/
+
+func (TCPTransport) Listen(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to open a TCP connection to the address
+ * @param: address string (host:port), timeout of the connection attempt
+ * @return: connection and error if any
+ **/
+
+func (TCPTransport) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("tcp", addr, timeout)
+}
@@ -0,0 +1,164 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Every frame on the wire starts with this header, followed by the payload (the encoded message):
+//
+//	magic (4 bytes) | message code (2 bytes) | payload length (4 bytes) | checksum (4 bytes)
+//
+// The checksum is the first 4 bytes of the SHA-256 of the payload. All integers are big endian.
+const protocolMagic uint32 = 0x4d4c4243 // "MLBC"
+const codeSize = 2
+const lengthOffset = 4 + codeSize
+const checksumOffset = lengthOffset + 4
+const frameHeaderSize = checksumOffset + 4
+
+const defaultMaxPayloadSize = 64 << 10 // Used for message types without their own limit
+
+var (
+	ErrBadMagic        = errors.New("frame does not start with the protocol magic")
+	ErrBadChecksum     = errors.New("frame checksum does not match its payload")
+	ErrFrameTooLarge   = errors.New("frame payload exceeds the maximum size of its message type")
+	ErrCommandMismatch = errors.New("frame message code does not match its payload")
+)
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to write the message of a built-in type as a single frame
+ * @param: writer of the connection, instance of message
+ * @return: error if any
+ **/
+
+func WriteFrame(w io.Writer, msg *Message) error {
+	return defaultMessages.WriteFrame(w, msg, JSONCodec{})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the message of a built-in type as a JSON frame, header included
+ * @param: instance of message
+ * @return: byte slice of the frame and error if the message type is unknown or the payload too large
+ **/
+
+func EncodeFrame(msg *Message) ([]byte, error) {
+	return defaultMessages.EncodeFrame(msg, JSONCodec{})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read the next JSON frame of a built-in message type
+ * @param: reader of the connection
+ * @return: instance of message and error if any, io.EOF if the connection was closed between frames
+ **/
+
+func ReadFrame(r io.Reader) (*Message, error) {
+	return defaultMessages.ReadFrame(r, JSONCodec{})
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to write the message as a single frame
+ * @description: The whole frame is written with one call so frames of concurrent writers never interleave.
+ * @param: writer of the connection, instance of message, codec of the connection
+ * @return: error if any
+ **/
+
+func (reg *MessageRegistry) WriteFrame(w io.Writer, msg *Message, codec Codec) error {
+	frame, err := reg.EncodeFrame(msg, codec)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(frame)
+	return err
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to encode the message as a frame, header included
+ * @param: instance of message, codec of the connection
+ * @return: byte slice of the frame and error if the message type is not registered or the payload too large
+ **/
+
+func (reg *MessageRegistry) EncodeFrame(msg *Message, codec Codec) ([]byte, error) {
+	t, ok := reg.Lookup(msg.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMessageType, msg.Type)
+	}
+	payload, err := encodeMessage(msg, codec)
+	if err != nil {
+		return nil, err
+	}
+	if uint32(len(payload)) > t.MaxPayload {
+		return nil, ErrFrameTooLarge
+	}
+
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], protocolMagic)
+	binary.BigEndian.PutUint16(frame[4:lengthOffset], uint16(t.Code))
+	binary.BigEndian.PutUint32(frame[lengthOffset:checksumOffset], uint32(len(payload)))
+	checksum := sha256.Sum256(payload)
+	copy(frame[checksumOffset:frameHeaderSize], checksum[:4])
+	copy(frame[frameHeaderSize:], payload)
+	return frame, nil
+}
+
+/**
+ * @createdby: Syed Muhammad Ammar
+ * @description: This function is used to read the next frame fully and decode its message
+ * @description: The size limit of the message type is enforced before the payload is read. A frame of an unknown
+ * @description: code is read with the default size limit, its message is returned for the node to decide what to do.
+ * @param: reader of the connection, codec of the connection
+ * @return: instance of message and error if any, io.EOF if the connection was closed between frames
+ **/
+
+func (reg *MessageRegistry) ReadFrame(r io.Reader, codec Codec) (*Message, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(header[0:4]) != protocolMagic {
+		return nil, ErrBadMagic
+	}
+
+	code := MessageCode(binary.BigEndian.Uint16(header[4:lengthOffset]))
+	t, known := reg.LookupCode(code)
+	maxPayload := uint32(defaultMaxPayloadSize)
+	if known {
+		maxPayload = t.MaxPayload
+	}
+	length := binary.BigEndian.Uint32(header[lengthOffset:checksumOffset])
+	if length > maxPayload {
+		return nil, fmt.Errorf("%w: frame of code 0x%04x and %d bytes", ErrFrameTooLarge, uint16(code), length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	checksum := sha256.Sum256(payload)
+	if !bytes.Equal(checksum[:4], header[checksumOffset:frameHeaderSize]) {
+		return nil, ErrBadChecksum
+	}
+
+	msg, err := decodeMessage(payload, codec)
+	if err != nil {
+		return nil, err
+	}
+	if known && msg.Type != t.Name {
+		return nil, ErrCommandMismatch
+	}
+	if _, ok := reg.Lookup(msg.Type); !known && ok {
+		return nil, ErrCommandMismatch
+	}
+	return msg, nil
+}